@@ -8,50 +8,36 @@ import (
 
 	"github.com/demostanis/42evaluators/internal/campus"
 	"github.com/demostanis/42evaluators/internal/clusters"
+	"github.com/demostanis/42evaluators/internal/cursus"
+	"github.com/demostanis/42evaluators/internal/evaluations"
 	"github.com/demostanis/42evaluators/internal/projects"
+	"github.com/demostanis/42evaluators/internal/slots"
 	"github.com/demostanis/42evaluators/internal/users"
 	"github.com/go-co-op/gocron/v2"
 	"gorm.io/gorm"
 )
 
-func getDisabledJobs() (bool, bool, bool, bool) {
-	if os.Getenv("disabledjobs") == "*" {
-		return true, true, true, true
-	}
-
+// Jobs can be disabled by listing them, separated by
+// commas, in the disabledjobs environment variable
+// (or * to disable all of them)
+func isJobDisabled(job string) bool {
 	disabledJobs := strings.Split(os.Getenv("disabledjobs"), ",")
-	disableCampusesJob := false
-	disableUsersJob := false
-	disableLocationsJob := false
-	disableProjectsJob := false
-
-	for _, job := range disabledJobs {
-		if job == "campuses" {
-			disableCampusesJob = true
-		}
-		if job == "users" {
-			disableUsersJob = true
-		}
-		if job == "locations" {
-			disableLocationsJob = true
-		}
-		if job == "projects" {
-			disableProjectsJob = true
+	for _, disabledJob := range disabledJobs {
+		if disabledJob == "*" || disabledJob == job {
+			return true
 		}
 	}
-
-	return disableCampusesJob,
-		disableUsersJob,
-		disableLocationsJob,
-		disableProjectsJob
+	return false
 }
 
 func setupCron(ctx context.Context, db *gorm.DB, errstream chan error) error {
-	var job1, job2, job3, job4 gocron.Job
-	disableCampusesJob,
-		disableUsersJob,
-		disableLocationsJob,
-		disableProjectsJob := getDisabledJobs()
+	var job1, job2, job3, job4, job5, job6 gocron.Job
+	disableCampusesJob := isJobDisabled("campuses")
+	disableUsersJob := isJobDisabled("users")
+	disableLocationsJob := isJobDisabled("locations")
+	disableProjectsJob := isJobDisabled("projects")
+	disableEvaluationsJob := isJobDisabled("evaluations")
+	disableSlotsJob := isJobDisabled("slots")
 
 	s, err := gocron.NewScheduler()
 	if err != nil {
@@ -62,7 +48,10 @@ func setupCron(ctx context.Context, db *gorm.DB, errstream chan error) error {
 			gocron.DailyJob(1, gocron.NewAtTimes(
 				gocron.NewAtTime(0, 0, 0))),
 			gocron.NewTask(
-				campus.GetCampuses,
+				func(db *gorm.DB, errstream chan error) {
+					campus.GetCampuses(db, errstream)
+					cursus.GetCursus(db, errstream)
+				},
 				db, errstream,
 			),
 		)
@@ -115,6 +104,30 @@ func setupCron(ctx context.Context, db *gorm.DB, errstream chan error) error {
 			return err
 		}
 	}
+	if !disableEvaluationsJob {
+		job5, err = s.NewJob(
+			gocron.DurationJob(time.Hour*4),
+			gocron.NewTask(
+				evaluations.GetEvaluations,
+				ctx, db, errstream,
+			),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if !disableSlotsJob {
+		job6, err = s.NewJob(
+			gocron.DurationJob(time.Minute*1),
+			gocron.NewTask(
+				slots.GetSlots,
+				ctx, db, errstream,
+			),
+		)
+		if err != nil {
+			return err
+		}
+	}
 	s.Start()
 	if !disableCampusesJob {
 		_ = job1.RunNow()
@@ -128,5 +141,11 @@ func setupCron(ctx context.Context, db *gorm.DB, errstream chan error) error {
 	if !disableProjectsJob {
 		_ = job4.RunNow()
 	}
+	if !disableEvaluationsJob {
+		_ = job5.RunNow()
+	}
+	if !disableSlotsJob {
+		_ = job6.RunNow()
+	}
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/demostanis/42evaluators/internal/api"
+	"github.com/demostanis/42evaluators/internal/clusters"
 	"github.com/demostanis/42evaluators/internal/database"
 	"github.com/demostanis/42evaluators/internal/projects"
 	"github.com/joho/godotenv"
@@ -30,11 +31,17 @@ func main() {
 		return
 	}
 
-	err = web.OpenClustersData()
+	err = clusters.OpenClustersData()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error opening clusters data:", err)
 		return
 	}
+	go func() {
+		err := clusters.WarmHosts()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error warming cluster hosts:", err)
+		}
+	}()
 
 	err = projects.OpenProjectData()
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"io"
 	"maps"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -46,6 +47,9 @@ type APIRequest struct {
 	maxConcurrentFetches int64
 	pageSize             string
 	startingDate         time.Time
+	endingDate           time.Time
+	checkpoint           *checkpoint
+	err                  error
 }
 
 func NewRequest(endpoint string) *APIRequest {
@@ -97,30 +101,21 @@ func (apiReq *APIRequest) WithPageSize(n int) *APIRequest {
 	return apiReq
 }
 
+// Only fetches what has been updated since the last complete
+// crawl of this endpoint. When used with DoPaginated, fetched
+// pages are checkpointed, so that an interrupted crawl resumes
+// where it stopped instead of skipping what it didn't fetch.
 func (apiReq *APIRequest) SinceLastFetch(db *gorm.DB, defaultTime time.Time) *APIRequest {
-	var startingDate time.Time
-
-	column := apiReq.endpoint[strings.LastIndexByte(apiReq.endpoint, '/')+1:]
-	now := time.Now()
-	err := db.
-		Limit(1).
-		Select(column).
-		Table("request_timestamps").
-		Find(&startingDate).Error
-	if err != nil || startingDate.IsZero() {
-		db.Exec(`CREATE TABLE IF NOT EXISTS request_timestamps ()`)
-		db.Exec(`ALTER TABLE request_timestamps
-				ADD IF NOT EXISTS ` + column + ` timestamp`)
-		db.Exec(`INSERT INTO request_timestamps(`+column+`)
-				VALUES (?)`, now)
-	}
-	db.Exec(`UPDATE request_timestamps SET `+column+` = ?`,
-		now)
-
-	if startingDate.IsZero() {
-		startingDate = defaultTime
-	}
-	apiReq.startingDate = startingDate
+	job := apiReq.endpoint[strings.LastIndexByte(apiReq.endpoint, '/')+1:]
+	checkpoint, err := loadCheckpoint(db, job, defaultTime)
+	if err != nil {
+		apiReq.err = err
+		return apiReq
+	}
+
+	apiReq.checkpoint = checkpoint
+	apiReq.startingDate = checkpoint.state.Watermark
+	apiReq.endingDate = checkpoint.state.WindowEnd
 	return apiReq
 }
 
@@ -144,7 +139,9 @@ func Do[T any](apiReq *APIRequest) (*T, error) {
 		var targetTarget *Target
 		for _, target := range targets {
 			for _, url := range target.URLs {
-				if strings.HasPrefix(apiReq.endpoint, url) {
+				// URLs are either prefixes or patterns like /v2/users/*/slots
+				matched, _ := path.Match(url, apiReq.endpoint)
+				if matched || strings.HasPrefix(apiReq.endpoint, url) {
 					targetTarget = &target
 					goto end
 				}
@@ -166,9 +163,13 @@ func Do[T any](apiReq *APIRequest) (*T, error) {
 
 	q := req.URL.Query()
 	if !apiReq.startingDate.IsZero() {
+		endingDate := apiReq.endingDate
+		if endingDate.IsZero() {
+			endingDate = time.Now()
+		}
 		startingDateStr := apiReq.startingDate.Format(time.RFC3339)
-		nowStr := time.Now().Format(time.RFC3339)
-		q.Add("range[updated_at]", startingDateStr+","+nowStr)
+		endingDateStr := endingDate.Format(time.RFC3339)
+		q.Add("range[updated_at]", startingDateStr+","+endingDateStr)
 	}
 
 	for key, value := range apiReq.params {
@@ -292,6 +293,12 @@ func getPageCount(apiReq *APIRequest) (int, error) {
 
 func DoPaginated[T []E, E any](apiReq *APIRequest) (chan func() (*E, error), error) {
 	resps := make(chan func() (*E, error))
+	if apiReq.err != nil {
+		return resps, apiReq.err
+	}
+	if apiReq.checkpoint != nil {
+		return doCheckpointed[T](apiReq, resps)
+	}
 	pageCount, err := getPageCount(apiReq)
 	if err != nil {
 		return resps, err
@@ -350,3 +357,202 @@ func DoPaginated[T []E, E any](apiReq *APIRequest) (chan func() (*E, error), err
 
 	return resps, nil
 }
+
+// Returns how many things are in the window of the
+// request, and the ID of the first one in that order
+func getWindowBound(apiReq *APIRequest, sort string) (int, int, error) {
+	var headers *http.Header
+	newReq := *apiReq
+	newReq.params = maps.Clone(apiReq.params)
+	newReq.params["sort"] = sort
+	newReq.params["page[size]"] = "1"
+	newReq.outputHeadersIn = &headers
+
+	page, err := Do[[]struct {
+		ID int `json:"id"`
+	}](&newReq)
+	if err != nil {
+		return 0, 0, PageCountError{err}
+	}
+	if len(*page) == 0 {
+		return 0, 0, nil
+	}
+	total, err := strconv.Atoi(headers.Get("X-Total"))
+	if err != nil {
+		return 0, 0, PageCountError{errors.New("no X-Total in response")}
+	}
+	return total, (*page)[0].ID, nil
+}
+
+// Splits the window of a checkpointed request in as
+// many ranges of IDs as can be fetched concurrently
+func splitWindow(apiReq *APIRequest, pageSize int) error {
+	total, firstID, err := getWindowBound(apiReq, "id")
+	if err != nil {
+		return err
+	}
+	lastID := firstID - 1
+	if total > 0 {
+		_, lastID, err = getWindowBound(apiReq, "-id")
+		if err != nil {
+			return err
+		}
+	}
+
+	maxConcurrentFetches := int(apiReq.maxConcurrentFetches)
+	if maxConcurrentFetches == 0 {
+		maxConcurrentFetches = defaultMaxConcurrentFetches
+	}
+	pageCount := (total + pageSize - 1) / pageSize
+	checkpoint := apiReq.checkpoint
+	return checkpoint.split(total, splitIDs(checkpoint.state.Job,
+		firstID, lastID, min(pageCount, maxConcurrentFetches)))
+}
+
+// Fetches the range by ascending IDs, one page at a time. Things
+// updated during the crawl leave the window, so page numbers
+// would shift. Pages are only marked as done once the consumer
+// received what follows them, which means it's done saving them
+// (consumers of checkpointed crawls are sequential). Returns
+// the cursor of the last page, which wasn't marked yet, and
+// whether the whole range could be fetched.
+func fetchRange[T []E, E any](
+	apiReq *APIRequest,
+	crawlRange models.CrawlRange,
+	pageSize int,
+	resps chan func() (*E, error),
+) (int, bool) {
+	checkpoint := apiReq.checkpoint
+	cursor := crawlRange.Cursor
+	// The cursor of the page the consumer might still be processing
+	pendingCursor, hasPending := 0, false
+	send := func(resp func() (*E, error)) {
+		resps <- resp
+		if hasPending {
+			err := checkpoint.advance(crawlRange.FirstID, pendingCursor)
+			if err != nil {
+				fmt.Printf("failed to save checkpoint of %s: %v\n",
+					apiReq.endpoint, err)
+			}
+			hasPending = false
+		}
+	}
+
+	for cursor < crawlRange.LastID {
+		newReq := *apiReq
+		newReq.params = maps.Clone(newReq.params)
+		newReq.params["sort"] = "id"
+		newReq.params["range[id]"] = fmt.Sprintf("%d,%d",
+			cursor+1, crawlRange.LastID)
+		newReq.params["page[number]"] = "1"
+		newReq.params["page[size]"] = strconv.Itoa(pageSize)
+
+		page, err := Do[[]json.RawMessage](&newReq)
+		APIStats.requestDone()
+		if err != nil {
+			// The next crawl will resume from here
+			send(func() (*E, error) { return nil, err })
+			return pendingCursor, false
+		}
+
+		pageCursor := cursor
+		for _, raw := range *page {
+			var elem E
+			var withID struct {
+				ID int `json:"id"`
+			}
+			err := json.Unmarshal(raw, &elem)
+			if err == nil {
+				err = json.Unmarshal(raw, &withID)
+			}
+			if err != nil {
+				parseErr := &ParseError{err, raw}
+				send(func() (*E, error) { return nil, parseErr })
+				continue
+			}
+			pageCursor = max(pageCursor, withID.ID)
+			send(func() (*E, error) { return &elem, nil })
+		}
+		cursor = pageCursor
+		if len(*page) < pageSize {
+			cursor = crawlRange.LastID
+		}
+		pendingCursor, hasPending = cursor, true
+	}
+	return pendingCursor, true
+}
+
+// Checkpointed crawls are split in ranges of IDs, which are
+// fetched concurrently and resumed where they stopped
+func doCheckpointed[T []E, E any](
+	apiReq *APIRequest,
+	resps chan func() (*E, error),
+) (chan func() (*E, error), error) {
+	checkpoint := apiReq.checkpoint
+	pageSize, err := strconv.Atoi(apiReq.pageSize)
+	if err != nil || pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if len(checkpoint.ranges) == 0 {
+		err = splitWindow(apiReq, pageSize)
+		if err != nil {
+			return resps, err
+		}
+	}
+
+	pending := checkpoint.pending()
+	pageCount := (checkpoint.state.Total + pageSize - 1) / pageSize
+	if len(checkpoint.ranges) > 0 {
+		pageCount = pageCount * len(pending) / len(checkpoint.ranges)
+	}
+	// Each range probably ends with a page which isn't full
+	APIStats.growTotalRequests(pageCount + len(pending))
+	fmt.Printf("fetching %d pages in %s, in %d ranges of IDs...\n",
+		pageCount, apiReq.endpoint, len(pending))
+
+	go func() {
+		type result struct {
+			firstID int
+			cursor  int
+			ok      bool
+		}
+		results := make(chan result, len(pending))
+		for _, crawlRange := range pending {
+			go func(crawlRange models.CrawlRange) {
+				cursor, ok := fetchRange[T](apiReq, crawlRange, pageSize, resps)
+				results <- result{crawlRange.FirstID, cursor, ok}
+			}(crawlRange)
+		}
+
+		complete := true
+		lastCursors := make([]result, 0, len(pending))
+		for range pending {
+			result := <-results
+			complete = complete && result.ok
+			lastCursors = append(lastCursors, result)
+		}
+		// To indicate every page has been fetched. Once this
+		// is received, everything before it has been saved.
+		resps <- func() (*E, error) { return nil, nil }
+
+		for _, result := range lastCursors {
+			if result.cursor == 0 {
+				continue
+			}
+			err := checkpoint.advance(result.firstID, result.cursor)
+			if err != nil {
+				fmt.Printf("failed to save checkpoint of %s: %v\n",
+					apiReq.endpoint, err)
+			}
+		}
+		if complete {
+			err := checkpoint.finish()
+			if err != nil {
+				fmt.Printf("failed to finish checkpoint of %s: %v\n",
+					apiReq.endpoint, err)
+			}
+		}
+	}()
+
+	return resps, nil
+}
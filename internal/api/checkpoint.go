@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+type checkpoint struct {
+	sync.Mutex
+	db     *gorm.DB
+	state  models.CrawlCheckpoint
+	ranges []models.CrawlRange
+}
+
+// Before checkpoints existed, watermarks were stored in
+// a column of request_timestamps named after the endpoint.
+func legacyWatermark(db *gorm.DB, job string) time.Time {
+	var watermark time.Time
+	db.
+		Limit(1).
+		Select(job).
+		Table("request_timestamps").
+		Find(&watermark)
+	return watermark
+}
+
+func loadCheckpoint(db *gorm.DB, job string, defaultTime time.Time) (*checkpoint, error) {
+	c := &checkpoint{db: db}
+
+	err := db.
+		Session(&gorm.Session{}).
+		Where("job = ?", job).
+		First(&c.state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.state = models.CrawlCheckpoint{
+			Job:       job,
+			Watermark: legacyWatermark(db, job),
+		}
+		if c.state.Watermark.IsZero() {
+			c.state.Watermark = defaultTime
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint for %s: %w", job, err)
+	}
+
+	if !c.state.WindowEnd.IsZero() {
+		err = db.
+			Where("job = ?", job).
+			Order("first_id").
+			Find(&c.ranges).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to load checkpoint for %s: %w", job, err)
+		}
+		fmt.Printf("resuming %s with %d ID ranges left\n",
+			job, len(c.pending()))
+	} else {
+		// The window is frozen so that it can be resumed
+		// later. Things updated in the meantime will be
+		// in the next one.
+		c.state.WindowEnd = time.Now().UTC()
+		err = db.
+			Where("job = ?", job).
+			Delete(&models.CrawlRange{}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, db.Save(&c.state).Error
+}
+
+// Splits the IDs between firstID and lastID in at most
+// count ranges of about the same size
+func splitIDs(job string, firstID int, lastID int, count int) []models.CrawlRange {
+	if lastID < firstID {
+		return nil
+	}
+	count = max(1, min(count, lastID-firstID+1))
+	span := (lastID - firstID + count) / count
+
+	ranges := make([]models.CrawlRange, 0, count)
+	for start := firstID; start <= lastID; start += span {
+		ranges = append(ranges, models.CrawlRange{
+			Job:     job,
+			FirstID: start,
+			LastID:  min(start+span-1, lastID),
+			Cursor:  start - 1,
+		})
+	}
+	return ranges
+}
+
+// Records how many things are in the window and which
+// ranges of IDs it's split in, before fetching them
+func (c *checkpoint) split(total int, ranges []models.CrawlRange) error {
+	c.Lock()
+	defer c.Unlock()
+	c.state.Total = total
+	c.ranges = ranges
+	if len(ranges) > 0 {
+		err := c.db.Save(&c.ranges).Error
+		if err != nil {
+			return err
+		}
+	}
+	return c.db.Save(&c.state).Error
+}
+
+// Returns the ranges which still have to be fetched
+func (c *checkpoint) pending() []models.CrawlRange {
+	pending := make([]models.CrawlRange, 0, len(c.ranges))
+	for _, crawlRange := range c.ranges {
+		if !crawlRange.Done() {
+			pending = append(pending, crawlRange)
+		}
+	}
+	return pending
+}
+
+// Records that everything of the range starting at
+// firstID up to cursor has been consumed
+func (c *checkpoint) advance(firstID int, cursor int) error {
+	c.Lock()
+	defer c.Unlock()
+	for i := range c.ranges {
+		if c.ranges[i].FirstID == firstID {
+			c.ranges[i].Cursor = max(c.ranges[i].Cursor, cursor)
+			return c.db.
+				Model(&models.CrawlRange{}).
+				Where("job = ? AND first_id = ?", c.state.Job, firstID).
+				Update("cursor", c.ranges[i].Cursor).Error
+		}
+	}
+	return fmt.Errorf("no range starting at %d in %s", firstID, c.state.Job)
+}
+
+// Advances the watermark to the end of the window, once
+// every range of it has been fetched and consumed
+func (c *checkpoint) finish() error {
+	c.Lock()
+	defer c.Unlock()
+	for _, crawlRange := range c.ranges {
+		if !crawlRange.Done() {
+			return fmt.Errorf("IDs %d to %d of %s weren't fetched",
+				crawlRange.Cursor+1, crawlRange.LastID, c.state.Job)
+		}
+	}
+	c.state.Watermark = c.state.WindowEnd
+	c.state.WindowEnd = time.Time{}
+	c.state.Total = 0
+	c.ranges = nil
+	err := c.db.Save(&c.state).Error
+	if err != nil {
+		return err
+	}
+	// If this fails, they'll be deleted when the next window starts
+	return c.db.
+		Where("job = ?", c.state.Job).
+		Delete(&models.CrawlRange{}).Error
+}
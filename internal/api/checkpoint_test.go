@@ -0,0 +1,208 @@
+package api
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// A database which only builds queries, so that
+// checkpoints can be tested without postgres
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	return db
+}
+
+func TestSplitIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		firstID int
+		lastID  int
+		count   int
+		want    [][2]int
+	}{
+		{
+			name:    "empty window",
+			firstID: 1,
+			lastID:  0,
+			count:   4,
+			want:    nil,
+		},
+		{
+			name:    "single ID",
+			firstID: 42,
+			lastID:  42,
+			count:   4,
+			want:    [][2]int{{42, 42}},
+		},
+		{
+			name:    "even split",
+			firstID: 1,
+			lastID:  100,
+			count:   4,
+			want:    [][2]int{{1, 25}, {26, 50}, {51, 75}, {76, 100}},
+		},
+		{
+			name:    "uneven split",
+			firstID: 10,
+			lastID:  20,
+			count:   3,
+			want:    [][2]int{{10, 13}, {14, 17}, {18, 20}},
+		},
+		{
+			name:    "more ranges than IDs",
+			firstID: 5,
+			lastID:  7,
+			count:   10,
+			want:    [][2]int{{5, 5}, {6, 6}, {7, 7}},
+		},
+		{
+			name:    "no concurrency",
+			firstID: 5,
+			lastID:  700,
+			count:   0,
+			want:    [][2]int{{5, 700}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got [][2]int
+			for _, crawlRange := range splitIDs("test", test.firstID, test.lastID, test.count) {
+				if crawlRange.Cursor != crawlRange.FirstID-1 {
+					t.Errorf("range %d-%d starts at cursor %d",
+						crawlRange.FirstID, crawlRange.LastID, crawlRange.Cursor)
+				}
+				got = append(got, [2]int{crawlRange.FirstID, crawlRange.LastID})
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointAdvance(t *testing.T) {
+	windowEnd := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	watermark := windowEnd.AddDate(0, 0, -1)
+
+	type step struct {
+		firstID int
+		cursor  int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		// First IDs of the ranges left afterwards
+		wantPending []int
+		wantFinish  bool
+	}{
+		{
+			name:        "nothing consumed",
+			steps:       nil,
+			wantPending: []int{1, 51},
+			wantFinish:  false,
+		},
+		{
+			name:        "one range consumed",
+			steps:       []step{{1, 20}, {1, 50}},
+			wantPending: []int{51},
+			wantFinish:  false,
+		},
+		{
+			name:        "ranges consumed out of order",
+			steps:       []step{{51, 100}, {1, 30}, {1, 50}},
+			wantPending: []int{},
+			wantFinish:  true,
+		},
+		{
+			name:        "late advance doesn't go back",
+			steps:       []step{{1, 50}, {1, 20}, {51, 100}},
+			wantPending: []int{},
+			wantFinish:  true,
+		},
+		{
+			name:        "range partly consumed",
+			steps:       []step{{51, 100}, {1, 49}},
+			wantPending: []int{1},
+			wantFinish:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &checkpoint{
+				db: dryRunDB(t),
+				state: models.CrawlCheckpoint{
+					Job:       "test",
+					Watermark: watermark,
+					WindowEnd: windowEnd,
+				},
+			}
+			err := c.split(100, splitIDs("test", 1, 100, 2))
+			if err != nil {
+				t.Fatalf("could not split window: %v", err)
+			}
+
+			for _, step := range test.steps {
+				err = c.advance(step.firstID, step.cursor)
+				if err != nil {
+					t.Fatalf("could not advance to %d: %v", step.cursor, err)
+				}
+			}
+			pending := make([]int, 0)
+			for _, crawlRange := range c.pending() {
+				pending = append(pending, crawlRange.FirstID)
+			}
+			if !slices.Equal(pending, test.wantPending) {
+				t.Errorf("got pending ranges %v, want %v", pending, test.wantPending)
+			}
+
+			err = c.finish()
+			if test.wantFinish {
+				if err != nil {
+					t.Fatalf("could not finish: %v", err)
+				}
+				if !c.state.Watermark.Equal(windowEnd) ||
+					!c.state.WindowEnd.IsZero() || len(c.ranges) != 0 {
+					t.Errorf("finish left %+v with %d ranges", c.state, len(c.ranges))
+				}
+			} else {
+				if err == nil {
+					t.Fatal("finished with ranges left")
+				}
+				if !c.state.Watermark.Equal(watermark) ||
+					!c.state.WindowEnd.Equal(windowEnd) {
+					t.Errorf("failed finish moved the window to %+v", c.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpointAdvanceUnknownRange(t *testing.T) {
+	c := &checkpoint{
+		db:    dryRunDB(t),
+		state: models.CrawlCheckpoint{Job: "test"},
+	}
+	err := c.split(10, splitIDs("test", 1, 10, 1))
+	if err != nil {
+		t.Fatalf("could not split window: %v", err)
+	}
+	if c.advance(5, 10) == nil {
+		t.Error("advanced a range which doesn't exist")
+	}
+}
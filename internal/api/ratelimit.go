@@ -40,6 +40,7 @@ var (
 			[]string{
 				"/v2/campus",
 				"/v2/cursus_users",
+				"/v2/cursus/",
 				"/v2/groups_users",
 				"/v2/coalitions_users",
 				"/v2/coalitions",
@@ -51,12 +52,14 @@ var (
 		newTarget(
 			[]string{
 				"/v2/locations",
+				"/v2/users/*/slots",
 			},
 			1./5.,
 		),
 		newTarget(
 			[]string{
 				"/v2/projects_users",
+				"/v2/scale_teams",
 			},
 			1./6.,
 		),
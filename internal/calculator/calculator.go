@@ -0,0 +1,153 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/demostanis/42evaluators/internal/projects"
+)
+
+const (
+	// Projects done while the coalition bonus
+	// is active give 42% more XP
+	CoalitionBonus = 1.42
+
+	// These come from the intra's blackhole formula,
+	// past MaxBlackholeXP no more days are given
+	BlackholeXPScale = 49980
+	MaxBlackholeXP   = 78880
+	BlackholeDaysPow = 0.45
+	BlackholeDaysMul = 483
+
+	// Outstanding projects can go up to 125
+	MaxMark = 125
+)
+
+type Project struct {
+	XP int
+	// Usually between 0 and 125
+	Mark           int
+	CoalitionBonus bool
+}
+
+type Result struct {
+	Level         float64 `json:"level"`
+	XP            int     `json:"xp"`
+	XPGained      int     `json:"xp_gained"`
+	XPToNextLevel int     `json:"xp_to_next_level"`
+	BlackholeDays int     `json:"blackhole_days"`
+}
+
+// Returns the XP earned with this project
+func (project Project) EarnedXP() float64 {
+	xp := float64(project.XP) * float64(project.Mark) / 100
+	if project.CoalitionBonus {
+		xp *= CoalitionBonus
+	}
+	return xp
+}
+
+// Returns the total XP of someone with the given level
+func XPForLevel(xpData []projects.XPByLevel, level float64) float64 {
+	if len(xpData) == 0 || level < 0 {
+		return 0
+	}
+	levelData := xpData[len(xpData)-1]
+	for _, data := range xpData {
+		if data.Level == int(level) {
+			levelData = data
+			break
+		}
+	}
+	return float64(levelData.XP) +
+		float64(levelData.XPToNextLevel)*(level-math.Floor(level))
+}
+
+// Returns the level of someone with the given total XP
+func LevelForXP(xpData []projects.XPByLevel, xp float64) float64 {
+	if len(xpData) == 0 {
+		return 0
+	}
+	for i, data := range xpData {
+		if float64(data.XP) > xp {
+			if i == 0 {
+				return 0
+			}
+			previous := xpData[i-1]
+			return float64(previous.Level) +
+				(xp-float64(previous.XP))/float64(previous.XPToNextLevel)
+		}
+	}
+	return float64(xpData[len(xpData)-1].Level)
+}
+
+// Returns the XP left to reach the level after the one for xp
+func xpToNextLevel(xpData []projects.XPByLevel, xp float64) int {
+	for _, data := range xpData {
+		if float64(data.XP) > xp {
+			return int(float64(data.XP) - xp)
+		}
+	}
+	return 0
+}
+
+// Returns the XP left to reach the level after the given one
+func XPToNextLevel(xpData []projects.XPByLevel, level float64) int {
+	return xpToNextLevel(xpData, XPForLevel(xpData, level))
+}
+
+// Returns an error if the level or one
+// of the projects doesn't make sense
+func Validate(xpData []projects.XPByLevel, level float64, projectList []Project) error {
+	maxLevel := 0.
+	if len(xpData) > 0 {
+		maxLevel = float64(xpData[len(xpData)-1].Level)
+	}
+	if !(level >= 0 && level <= maxLevel) {
+		return fmt.Errorf("level should be between 0 and %.0f", maxLevel)
+	}
+	for _, project := range projectList {
+		if project.XP < 0 {
+			return errors.New("XP cannot be negative")
+		}
+		if project.Mark < 0 || project.Mark > MaxMark {
+			return fmt.Errorf("marks should be between 0 and %d", MaxMark)
+		}
+	}
+	return nil
+}
+
+// Returns how many days going from oldXP to newXP
+// pushes back the blackhole. This is never negative
+// when gaining XP.
+func BlackholeDays(oldXP float64, newXP float64) int {
+	days := int((math.Pow(math.Min(newXP, MaxBlackholeXP)/BlackholeXPScale, BlackholeDaysPow) -
+		math.Pow(oldXP/BlackholeXPScale, BlackholeDaysPow)) * BlackholeDaysMul)
+	if oldXP <= newXP && days < 0 {
+		return 0
+	}
+	return days
+}
+
+// Computes the level reached when doing every project
+// of the list, starting from the given level
+func Calculate(
+	xpData []projects.XPByLevel,
+	level float64,
+	projectList []Project,
+) Result {
+	initialXP := XPForLevel(xpData, level)
+	xp := initialXP
+	for _, project := range projectList {
+		xp += project.EarnedXP()
+	}
+
+	return Result{
+		Level:         LevelForXP(xpData, xp),
+		XP:            int(xp),
+		XPGained:      int(xp - initialXP),
+		XPToNextLevel: xpToNextLevel(xpData, xp),
+		BlackholeDays: BlackholeDays(initialXP, xp),
+	}
+}
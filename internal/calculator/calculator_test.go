@@ -0,0 +1,172 @@
+package calculator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/demostanis/42evaluators/internal/projects"
+)
+
+func readXPData(t *testing.T) []projects.XPByLevel {
+	xpData, err := projects.ReadXPData("../../assets/xp.json")
+	if err != nil {
+		t.Fatalf("could not read xp data: %v", err)
+	}
+	return xpData
+}
+
+func TestCalculate(t *testing.T) {
+	xpData := readXPData(t)
+
+	tests := []struct {
+		name     string
+		level    float64
+		projects []Project
+		want     Result
+	}{
+		{
+			name:     "no projects",
+			level:    3.5,
+			projects: nil,
+			want:     Result{Level: 3.5, XP: 8831, XPToNextLevel: 2946},
+		},
+		{
+			name:     "reaching the next level exactly",
+			level:    0,
+			projects: []Project{{XP: 462, Mark: 100}},
+			want: Result{Level: 1, XP: 462, XPGained: 462,
+				XPToNextLevel: 2226, BlackholeDays: 58},
+		},
+		{
+			name:     "outstanding mark",
+			level:    3.5,
+			projects: []Project{{XP: 25200, Mark: 125}},
+			want: Result{Level: 5.65, XP: 40331, XPGained: 31500,
+				XPToNextLevel: 5924, BlackholeDays: 217},
+		},
+		{
+			name:  "coalition bonus",
+			level: 4.2,
+			projects: []Project{
+				{XP: 9450, Mark: 100, CoalitionBonus: true},
+				{XP: 2100, Mark: 80},
+			},
+			want: Result{Level: 5.07, XP: 30364, XPGained: 15099,
+				XPToNextLevel: 15890, BlackholeDays: 102},
+		},
+		{
+			name:     "failed project",
+			level:    10,
+			projects: []Project{{XP: 6300, Mark: 0}},
+			want:     Result{Level: 10, XP: 95000, XPToNextLevel: 10630},
+		},
+		{
+			name:     "no more blackhole days past the cap",
+			level:    21,
+			projects: []Project{{XP: 50000, Mark: 100}},
+			want: Result{Level: 21.76, XP: 507632, XPGained: 50000,
+				XPToNextLevel: 15688},
+		},
+		{
+			name:     "maximum level",
+			level:    29.9,
+			projects: []Project{{XP: 1000000, Mark: 100}},
+			want:     Result{Level: 30, XP: 2466211, XPGained: 1000000},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Calculate(xpData, test.level, test.projects)
+			if math.Abs(got.Level-test.want.Level) > 0.01 {
+				t.Errorf("level: got %.4f, want %.2f", got.Level, test.want.Level)
+			}
+			got.Level = test.want.Level
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLevelForXPRoundTrips(t *testing.T) {
+	xpData := readXPData(t)
+
+	for _, level := range []float64{0, 0.5, 1, 7.42, 12.99, 21, 29.5} {
+		got := LevelForXP(xpData, XPForLevel(xpData, level))
+		if math.Abs(got-level) > 1e-9 {
+			t.Errorf("level %.2f: got %.4f back", level, got)
+		}
+	}
+}
+
+func TestBlackholeDays(t *testing.T) {
+	tests := []struct {
+		oldXP float64
+		newXP float64
+		want  int
+	}{
+		{0, 462, 58},
+		{462, 2688, 70},
+		{8831, 8831, 0},
+		{2688, 462, -70},
+		{100000, 200000, 0},
+	}
+
+	for _, test := range tests {
+		got := BlackholeDays(test.oldXP, test.newXP)
+		if got != test.want {
+			t.Errorf("BlackholeDays(%v, %v): got %d, want %d",
+				test.oldXP, test.newXP, got, test.want)
+		}
+	}
+}
+
+func TestXPToNextLevel(t *testing.T) {
+	xpData := readXPData(t)
+
+	tests := []struct {
+		level float64
+		want  int
+	}{
+		{0, 462},
+		{3.5, 2946},
+		{30, 0},
+	}
+
+	for _, test := range tests {
+		got := XPToNextLevel(xpData, test.level)
+		if got != test.want {
+			t.Errorf("XPToNextLevel(%v): got %d, want %d",
+				test.level, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	xpData := readXPData(t)
+
+	tests := []struct {
+		name     string
+		level    float64
+		projects []Project
+		valid    bool
+	}{
+		{"valid", 4.2, []Project{{XP: 9450, Mark: 125}}, true},
+		{"negative level", -1, nil, false},
+		{"level above the maximum", 31, nil, false},
+		{"NaN level", math.NaN(), nil, false},
+		{"negative mark", 4.2, []Project{{XP: 9450, Mark: -10}}, false},
+		{"mark above 125", 4.2, []Project{{XP: 9450, Mark: 1000}}, false},
+		{"negative XP", 4.2, []Project{{XP: -9450, Mark: 100}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(xpData, test.level, test.projects)
+			if (err == nil) != test.valid {
+				t.Errorf("got %v, want valid=%v", err, test.valid)
+			}
+		})
+	}
+}
@@ -34,7 +34,8 @@ type Location struct {
 			} `json:"versions"`
 		} `json:"image"`
 	} `json:"user"`
-	EndAt string `json:"end_at"`
+	BeginAt string `json:"begin_at"`
+	EndAt   string `json:"end_at"`
 }
 
 type Cluster struct {
@@ -79,7 +80,16 @@ func UpdateLocationInDB(location models.Location, db *gorm.DB) error {
 		db.Create(&location)
 	}
 	if location.EndAt != "" {
-		return db.Delete(&location).Error
+		// Don't keep people on campus forever if this fails
+		archiveErr := archiveLocation(location, db)
+		err = db.Delete(&location).Error
+		if err != nil {
+			return err
+		}
+		if archiveErr != nil {
+			return fmt.Errorf("failed to archive location: %w", archiveErr)
+		}
+		return nil
 	}
 	return db.
 		Model(&newLocation).
@@ -91,31 +101,39 @@ func UpdateLocationInDB(location models.Location, db *gorm.DB) error {
 			"Login":    location.Login,
 			"Host":     location.Host,
 			"CampusID": location.CampusID,
+			"BeginAt":  location.BeginAt,
 			"EndAt":    location.EndAt,
 			"Image":    location.Image,
 		}).Error
 }
 
+// Returns the IDs of the fetched locations, and whether all of
+// them could be fetched. They are sent to LocationChannel if
+// broadcast is set.
 func getLocationsForField(
 	lastFetch time.Time,
 	field string,
+	broadcast bool,
 	ctx context.Context,
 	db *gorm.DB,
 	errstream chan error,
-) {
+) ([]int, bool) {
 	locations, err := api.DoPaginated[[]Location](
 		api.NewRequest("/v2/locations").
 			Authenticated().
 			WithParams(getParams(lastFetch, field)))
 	if err != nil {
 		errstream <- err
-		return
+		return nil, false
 	}
 
+	ids := make([]int, 0)
+	complete := true
 	for {
 		location, err := (<-locations)()
 		if err != nil {
 			errstream <- err
+			complete = false
 			continue
 		}
 		if location == nil {
@@ -128,17 +146,20 @@ func getLocationsForField(
 			Host:     location.Host,
 			CampusID: location.CampusID,
 			Image:    location.User.Image.Versions.Small,
+			BeginAt:  location.BeginAt,
 			EndAt:    location.EndAt,
 		}
+		ids = append(ids, location.ID)
 		err = UpdateLocationInDB(dbLocation, db)
 		if err != nil {
 			errstream <- err
 			continue
 		}
-		if !lastFetch.IsZero() {
+		if broadcast {
 			LocationChannel <- dbLocation
 		}
 	}
+	return ids, complete
 }
 
 func GetLocations(
@@ -148,14 +169,45 @@ func GetLocations(
 	errstream chan error,
 ) {
 	if lastFetch.IsZero() {
-		// Makes everything easier
-		db.Exec("DELETE FROM locations")
-	}
-	// Don't do them in parallel, we need end_at to have
-	// more importance than begin_at
-	getLocationsForField(lastFetch, "begin_at", ctx, db, errstream)
-	if !lastFetch.IsZero() {
-		getLocationsForField(lastFetch, "end_at", ctx, db, errstream)
+		catchUpStart, err := getArchiveCatchUpStart(db, getLocationRetention())
+		if err != nil {
+			errstream <- fmt.Errorf("failed to find last archived session: %w", err)
+		}
+
+		activeIDs, complete := getLocationsForField(
+			lastFetch, "begin_at", false, ctx, db, errstream)
+		// Archives the sessions which ended while we were down,
+		// which clients don't need to know about
+		if !catchUpStart.IsZero() {
+			getLocationsForField(catchUpStart, "end_at", false, ctx, db, errstream)
+		}
+		// Whatever is left isn't active anymore
+		if complete {
+			err = db.
+				Where("id NOT IN ?", append(activeIDs, 0)).
+				Delete(&models.Location{}).Error
+			if err != nil {
+				errstream <- fmt.Errorf("failed to remove ended locations: %w", err)
+			}
+		}
+	} else {
+		// Don't do them in parallel, we need end_at to have
+		// more importance than begin_at
+		getLocationsForField(lastFetch, "begin_at", true, ctx, db, errstream)
+		getLocationsForField(lastFetch, "end_at", true, ctx, db, errstream)
 	}
 	FirstFetchDone = true
+
+	err := pruneLocationSessions(db)
+	if err != nil {
+		errstream <- fmt.Errorf("failed to prune location sessions: %w", err)
+	}
+	err = sampleOccupancy(db)
+	if err != nil {
+		errstream <- fmt.Errorf("failed to sample cluster occupancy: %w", err)
+	}
+	err = pruneOccupancies(db)
+	if err != nil {
+		errstream <- fmt.Errorf("failed to prune cluster occupancy: %w", err)
+	}
 }
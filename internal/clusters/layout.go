@@ -0,0 +1,217 @@
+package clusters
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+)
+
+var AllClusters []Cluster
+
+func OpenClustersData() error {
+	file, err := os.Open("assets/clusters.json")
+	if err != nil {
+		return err
+	}
+	bytes, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(bytes, &AllClusters)
+	if err != nil {
+		return err
+	}
+	for i, c := range AllClusters {
+		AllClusters[i].DisplayName = fmt.Sprintf(
+			"%s - %s", c.Campus.Name, c.Name)
+	}
+	slices.SortFunc(AllClusters, func(a, b Cluster) int {
+		return cmp.Compare(a.DisplayName, b.DisplayName)
+	})
+	return nil
+}
+
+const (
+	svgFetchTimeout        = 10 * time.Second
+	concurrentHostsFetches = 10
+	// Missing cluster maps are only looked for again after that
+	missingMapRetryDelay = time.Hour
+	// Same for cluster maps which couldn't be fetched, so that
+	// callers don't wait for the CDN every time it's down
+	failedMapRetryDelay = 10 * time.Minute
+)
+
+var svgClient = &http.Client{Timeout: svgFetchTimeout}
+
+// Returns the SVG of the cluster map, either from
+// the intra's CDN or from web/static/clusters
+func readSvg(cluster Cluster) (string, bool, error) {
+	var source io.Reader
+
+	if strings.HasPrefix(cluster.Image, "http") {
+		resp, err := svgClient.Get(cluster.Image)
+		if err != nil {
+			return "", false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", false, nil
+		}
+		source = resp.Body
+	} else {
+		file, err := os.Open(cluster.Image)
+		if err != nil {
+			return "", false, err
+		}
+		defer file.Close()
+		source = file
+	}
+
+	body, err := io.ReadAll(source)
+	if err != nil {
+		return "", false, err
+	}
+	return string(body), true, nil
+}
+
+func FetchSvg(cluster *Cluster) error {
+	svg, found, err := readSvg(*cluster)
+	if err != nil {
+		return err
+	}
+	if !found {
+		cluster.Svg = "<p class=\"h-[90%] flex justify-center m-5 text-center items-center\">Cannot find this cluster map. It's likely that " +
+			"its campus' staff has modified it, and thus the link has changed. " +
+			"If you are part of this campus, please send the cluster SVG to " +
+			"@cgodard on Slack.</p>"
+		return nil
+	}
+	cluster.Svg = strings.Replace(svg, "<svg", "<svg width=\"100%\" height=\"90%\" class=\"p-5 absolute\"", 1)
+	return nil
+}
+
+// Seats are <image>s whose ID is the host
+var hostRegexp = regexp.MustCompile(`<image[^>]*\sid="([^"]+)"`)
+
+var (
+	clusterHosts = make(map[int][]string)
+	// When to look for each missing cluster map again
+	missingClusterMaps = make(map[int]time.Time)
+	// Why each cluster map couldn't be fetched,
+	// until it's fetched again
+	failedClusterMaps = make(map[int]failedClusterMap)
+	clusterHostsMu    sync.RWMutex
+	// So that a cluster map is only fetched once
+	// even if many people ask for it at once
+	clusterHostsFetches singleflight.Group
+)
+
+type failedClusterMap struct {
+	err     error
+	retryAt time.Time
+}
+
+func fetchHosts(cluster Cluster) ([]string, error) {
+	svg, found, err := readSvg(cluster)
+	if err != nil {
+		clusterHostsMu.Lock()
+		failedClusterMaps[cluster.ID] = failedClusterMap{
+			err:     err,
+			retryAt: time.Now().Add(failedMapRetryDelay),
+		}
+		clusterHostsMu.Unlock()
+		return nil, err
+	}
+	if !found {
+		clusterHostsMu.Lock()
+		missingClusterMaps[cluster.ID] = time.Now().Add(missingMapRetryDelay)
+		clusterHostsMu.Unlock()
+		return nil, nil
+	}
+	hosts := make([]string, 0)
+	for _, match := range hostRegexp.FindAllStringSubmatch(svg, -1) {
+		if !slices.Contains(hosts, match[1]) {
+			hosts = append(hosts, match[1])
+		}
+	}
+
+	clusterHostsMu.Lock()
+	clusterHosts[cluster.ID] = hosts
+	delete(failedClusterMaps, cluster.ID)
+	clusterHostsMu.Unlock()
+	return hosts, nil
+}
+
+// Returns the hosts of the seats of the cluster. Cluster
+// maps are only fetched once, since they rarely change,
+// and errors are remembered for failedMapRetryDelay.
+func GetHosts(cluster Cluster) ([]string, error) {
+	clusterHostsMu.RLock()
+	hosts, ok := clusterHosts[cluster.ID]
+	retryAt, missing := missingClusterMaps[cluster.ID]
+	failed, hasFailed := failedClusterMaps[cluster.ID]
+	clusterHostsMu.RUnlock()
+	if ok {
+		return hosts, nil
+	}
+	if missing && time.Now().Before(retryAt) {
+		return nil, nil
+	}
+	if hasFailed && time.Now().Before(failed.retryAt) {
+		return nil, failed.err
+	}
+
+	result, err, _ := clusterHostsFetches.Do(strconv.Itoa(cluster.ID),
+		func() (any, error) {
+			return fetchHosts(cluster)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// Fetches the hosts of every cluster, so that
+// the first requests don't have to wait for them
+func WarmHosts() error {
+	var g errgroup.Group
+	g.SetLimit(concurrentHostsFetches)
+	for _, cluster := range AllClusters {
+		cluster := cluster
+		g.Go(func() error {
+			_, err := GetHosts(cluster)
+			if err != nil {
+				return fmt.Errorf("error fetching hosts of %s: %w",
+					cluster.DisplayName, err)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// Returns the cluster of the campus whose map contains the host
+func FindClusterOfHost(campusID int, host string) (Cluster, bool) {
+	for _, cluster := range AllClusters {
+		if cluster.Campus.ID != campusID {
+			continue
+		}
+		hosts, err := GetHosts(cluster)
+		if err == nil && slices.Contains(hosts, host) {
+			return cluster, true
+		}
+	}
+	return Cluster{}, false
+}
@@ -0,0 +1,91 @@
+package clusters
+
+import (
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	OccupancySamplingInterval = 15 * time.Minute
+	// How long samples are kept, and how far back the heatmap looks
+	OccupancyHistory = 28 * 24 * time.Hour
+)
+
+var (
+	lastOccupancySample time.Time
+	lastOccupancyPrune  time.Time
+)
+
+// Records how many seats of each cluster are taken. This is
+// called by the locations job every minute, but only samples
+// every OccupancySamplingInterval.
+func sampleOccupancy(db *gorm.DB) error {
+	now := time.Now().UTC()
+	if now.Sub(lastOccupancySample) < OccupancySamplingInterval {
+		return nil
+	}
+
+	var locations []models.Location
+	err := db.
+		Model(&models.Location{}).
+		Select("host, campus_id").
+		Find(&locations).Error
+	if err != nil {
+		return err
+	}
+	type seat struct {
+		campusID int
+		host     string
+	}
+	takenSeats := make(map[seat]bool)
+	for _, location := range locations {
+		takenSeats[seat{location.CampusID, location.Host}] = true
+	}
+
+	at := now.Truncate(OccupancySamplingInterval)
+	occupancies := make([]models.ClusterOccupancy, 0, len(AllClusters))
+	for _, cluster := range AllClusters {
+		hosts, err := GetHosts(cluster)
+		if err != nil || len(hosts) == 0 {
+			// The map probably moved, there's
+			// nothing we can do about it
+			continue
+		}
+		occupancy := models.ClusterOccupancy{
+			ClusterID: cluster.ID,
+			At:        at,
+			Seats:     len(hosts),
+		}
+		for _, host := range hosts {
+			if takenSeats[seat{cluster.Campus.ID, host}] {
+				occupancy.Occupied++
+			}
+		}
+		occupancies = append(occupancies, occupancy)
+	}
+
+	if len(occupancies) > 0 {
+		err = db.Save(&occupancies).Error
+		if err != nil {
+			return err
+		}
+	}
+	lastOccupancySample = now
+	return nil
+}
+
+// Drops samples older than OccupancyHistory, once a day
+func pruneOccupancies(db *gorm.DB) error {
+	if time.Since(lastOccupancyPrune) < 24*time.Hour {
+		return nil
+	}
+	err := db.
+		Where("at < ?", time.Now().UTC().Add(-OccupancyHistory)).
+		Delete(&models.ClusterOccupancy{}).Error
+	if err == nil {
+		lastOccupancyPrune = time.Now()
+	}
+	return err
+}
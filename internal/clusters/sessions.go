@@ -0,0 +1,110 @@
+package clusters
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How many months of location sessions to keep,
+// e.g. locationretentionmonths=6. Defaults to a year.
+const LocationRetentionVar = "locationretentionmonths"
+
+const defaultLocationRetention = 12
+
+var lastPrune time.Time
+
+func getLocationRetention() int {
+	months, err := strconv.Atoi(os.Getenv(LocationRetentionVar))
+	if err != nil || months <= 0 {
+		return defaultLocationRetention
+	}
+	return months
+}
+
+func archiveLocation(location models.Location, db *gorm.DB) error {
+	beginAt, err := time.Parse(time.RFC3339, location.BeginAt)
+	if err != nil {
+		return err
+	}
+	endAt, err := time.Parse(time.RFC3339, location.EndAt)
+	if err != nil {
+		return err
+	}
+
+	err = database.EnsureSessionsPartition(db, beginAt)
+	if err != nil {
+		return err
+	}
+	return db.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LocationSession{
+			ID:       location.ID,
+			UserID:   location.UserID,
+			Login:    location.Login,
+			Host:     location.Host,
+			CampusID: location.CampusID,
+			BeginAt:  beginAt,
+			EndAt:    endAt,
+		}).Error
+}
+
+// How far back to archive sessions the first time, since
+// the oldest location we have can be from long ago
+const firstArchiveCatchUp = 24 * time.Hour
+
+// Returns when to start archiving the sessions which ended while
+// we were down: when the last archived one ended, or if none were
+// archived yet, when the oldest location we still have began, up
+// to a day ago. Zero means there's nothing to catch up with.
+func getArchiveCatchUpStart(db *gorm.DB, retention int) (time.Time, error) {
+	var lastEnd *time.Time
+	err := db.
+		Model(&models.LocationSession{}).
+		Select("MAX(end_at)").
+		Scan(&lastEnd).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lastEnd == nil {
+		var firstBegin *string
+		err = db.
+			Model(&models.Location{}).
+			Select("MIN(NULLIF(begin_at, ''))").
+			Scan(&firstBegin).Error
+		if err != nil || firstBegin == nil {
+			return time.Time{}, err
+		}
+		begin, err := time.Parse(time.RFC3339, *firstBegin)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if since := time.Now().UTC().Add(-firstArchiveCatchUp); begin.Before(since) {
+			begin = since
+		}
+		lastEnd = &begin
+	}
+	oldest := time.Now().UTC().AddDate(0, -retention, 0)
+	if lastEnd.Before(oldest) {
+		return oldest, nil
+	}
+	return *lastEnd, nil
+}
+
+// Drops sessions older than the retention period, once a day
+func pruneLocationSessions(db *gorm.DB) error {
+	if time.Since(lastPrune) < 24*time.Hour {
+		return nil
+	}
+	err := database.DropSessionsPartitionsBefore(db,
+		time.Now().UTC().AddDate(0, -getLocationRetention(), 0))
+	if err == nil {
+		lastPrune = time.Now()
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package cursus
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/demostanis/42evaluators/internal/api"
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+const CursusVar = "cursus"
+
+// Returns the IDs of the cursuses to crawl, from the
+// comma-separated cursus environment variable (e.g.
+// cursus=21,9 to also fetch piscines). Defaults to
+// the main cursus.
+func Enabled() []int {
+	cursusIDs := make([]int, 0)
+	for _, cursusIDRaw := range strings.Split(os.Getenv(CursusVar), ",") {
+		cursusID, err := strconv.Atoi(strings.TrimSpace(cursusIDRaw))
+		if err == nil {
+			cursusIDs = append(cursusIDs, cursusID)
+		}
+	}
+	if len(cursusIDs) == 0 {
+		cursusIDs = append(cursusIDs, models.MainCursusID)
+	}
+	return cursusIDs
+}
+
+func IsEnabled(cursusID int) bool {
+	for _, enabledCursusID := range Enabled() {
+		if enabledCursusID == cursusID {
+			return true
+		}
+	}
+	return false
+}
+
+func GetCursus(db *gorm.DB, errstream chan error) {
+	for _, cursusID := range Enabled() {
+		cursus, err := api.Do[models.Cursus](
+			api.NewRequest(fmt.Sprintf("/v2/cursus/%d", cursusID)).
+				Authenticated())
+		if err != nil {
+			errstream <- fmt.Errorf("error while fetching cursus %d: %w", cursusID, err)
+			continue
+		}
+		err = db.Save(&cursus).Error
+		if err != nil {
+			errstream <- err
+		}
+	}
+}
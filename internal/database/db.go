@@ -44,6 +44,15 @@ func newDB(dialector gorm.Dialector) (*gorm.DB, error) {
 	if err = db.AutoMigrate(models.Campus{}); err != nil {
 		return nil, err
 	}
+	if err = db.AutoMigrate(models.Cursus{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.CursusUser{}); err != nil {
+		return nil, err
+	}
+	if err = seedMainCursusUsers(db); err != nil {
+		return nil, err
+	}
 	if err = db.AutoMigrate(models.Subject{}); err != nil {
 		return nil, err
 	}
@@ -53,9 +62,52 @@ func newDB(dialector gorm.Dialector) (*gorm.DB, error) {
 	if err = db.AutoMigrate(models.Team{}); err != nil {
 		return nil, err
 	}
+	projectTeamsNeedBackfill := db.Migrator().HasTable(&models.Team{}) &&
+		!db.Migrator().HasTable("project_teams")
 	if err = db.AutoMigrate(models.Project{}); err != nil {
 		return nil, err
 	}
+	if projectTeamsNeedBackfill {
+		if err = backfillProjectTeams(db); err != nil {
+			return nil, err
+		}
+	}
+	if err = db.AutoMigrate(models.Slot{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.Evaluation{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.LevelSnapshot{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.LogtimeDay{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.PointsHistory{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.CalculatorPlan{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.LFGEntry{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.ClusterOccupancy{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.UserChange{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.CrawlCheckpoint{}); err != nil {
+		return nil, err
+	}
+	if err = db.AutoMigrate(models.CrawlRange{}); err != nil {
+		return nil, err
+	}
+	if err = createLocationSessionsTable(db); err != nil {
+		return nil, err
+	}
 	if err = db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
 		return nil, err
 	}
@@ -67,6 +119,50 @@ func newDB(dialector gorm.Dialector) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Before cursus_users existed, everyone in users was from the
+// main cursus, with its level, blackhole and begin date. Copy
+// them, otherwise the leaderboard stays empty until the next
+// complete crawl.
+func seedMainCursusUsers(db *gorm.DB) error {
+	var seeded bool
+	err := db.
+		Model(&models.CursusUser{}).
+		Select("COUNT(*) > 0").
+		Where("cursus_id = ?", models.MainCursusID).
+		Find(&seeded).Error
+	if err != nil || seeded {
+		return err
+	}
+	return db.Exec(`INSERT INTO cursus_users
+		(user_id, cursus_id, level, begin_at, blackholed_at)
+		SELECT id, ?, level, begin_at, blackholed_at FROM users
+		-- Users only fetched from other cursuses have no begin date
+		WHERE EXTRACT(YEAR FROM begin_at) > 1
+		ON CONFLICT DO NOTHING`, models.MainCursusID).Error
+}
+
+// Before project_teams existed, teams were only linked
+// to the project of one of their members, so link them to
+// the projects of the other ones with the same subject
+func backfillProjectTeams(db *gorm.DB) error {
+	err := db.Exec(`INSERT INTO project_teams (project_id, team_id)
+		SELECT project_id, id FROM teams
+		WHERE project_id != 0
+		ON CONFLICT DO NOTHING`).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO project_teams (project_id, team_id)
+		SELECT projects.id, teams.id FROM projects
+		JOIN team_users ON team_users.user_id = projects.user_id
+		JOIN teams ON teams.id = team_users.team_id
+		JOIN projects linked ON linked.id = teams.project_id
+		WHERE projects.user_id != 0
+		AND linked.subject_id = projects.subject_id
+		AND linked.cursus_id = projects.cursus_id
+		ON CONFLICT DO NOTHING`).Error
+}
+
 func OpenDB() (*gorm.DB, error) {
 	return newDB(postgres.Open("host=localhost"))
 }
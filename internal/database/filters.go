@@ -2,6 +2,8 @@ package database
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/demostanis/42evaluators/internal/models"
@@ -21,7 +23,7 @@ func WithCampus(campusID string) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
-const OnlyRealUsersCondition = "is_staff = false AND is_test = false AND login != ''"
+const OnlyRealUsersCondition = "is_staff = false AND is_test = false AND is_inactive = false AND login != ''"
 
 func OnlyRealUsers() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -29,6 +31,49 @@ func OnlyRealUsers() func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// Users crawled through the piscine or other cursuses are
+// in users too, but most pages are about the main cursus
+var MainCursusCondition = fmt.Sprintf(`EXISTS (SELECT 1 FROM cursus_users
+	WHERE cursus_users.user_id = users.id
+	AND cursus_users.cursus_id = %d)`, models.MainCursusID)
+
+func OnlyMainCursusUsers() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Scopes(OnlyRealUsers()).
+			Where(MainCursusCondition)
+	}
+}
+
+var cursusColumns = []string{"level", "blackholed_at", "begin_at"}
+
+// Users' level, blackhole and begin date are the ones of the main
+// cursus. This replaces the users table with one where they are
+// taken from the given cursus, and which only contains its users.
+func WithCursus(cursusID int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&models.User{}); err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+
+		columns := make([]string, 0, len(stmt.Schema.DBNames))
+		for _, column := range stmt.Schema.DBNames {
+			if slices.Contains(cursusColumns, column) {
+				columns = append(columns, "cursus_users."+column)
+			} else {
+				columns = append(columns, "users."+column)
+			}
+		}
+
+		return db.Table(fmt.Sprintf(`(SELECT %s FROM users
+			JOIN cursus_users ON cursus_users.user_id = users.id
+			AND cursus_users.cursus_id = %d) users`,
+			strings.Join(columns, ", "), cursusID))
+	}
+}
+
 func WithPromo(promo string) func(db *gorm.DB) *gorm.DB {
 	promoBeginAt, err := time.Parse(PromoFormat, promo)
 
@@ -44,6 +89,44 @@ func WithPromo(promo string) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// Same as WithPromo, for queries which joined the
+// cursus_users of the cursus they are about
+func WithCursusPromo(promo string) func(db *gorm.DB) *gorm.DB {
+	promoBeginAt, err := time.Parse(PromoFormat, promo)
+
+	return func(db *gorm.DB) *gorm.DB {
+		if err == nil {
+			return db.Where("DATE_TRUNC('month', cursus_users.begin_at) = ?",
+				promoBeginAt)
+		}
+		return db
+	}
+}
+
 const UnwantedSubjectsCondition = `name NOT LIKE 'Day %' AND
 	name NOT LIKE '%DEPRECATED%' AND
 	name NOT LIKE 'Rush %'`
+
+// Projects fetched before we stored validated? only
+// have their status and mark to tell whether they were
+const ValidatedProjectCondition = `(projects.validated = true OR
+	(projects.status = 'finished' AND projects.final_mark >= 50))`
+
+// Projects fetched before projects.user_id existed only have
+// their teams to tell whose they are, until they're fetched again
+const ProjectUserCondition = `(users.id = projects.user_id OR
+	(projects.user_id = 0 AND users.id IN (SELECT team_users.user_id
+		FROM project_teams
+		JOIN team_users ON team_users.team_id = project_teams.team_id
+		WHERE project_teams.project_id = projects.id)))`
+
+// Returns a subquery of the IDs of users who
+// validated the given subject
+func UsersWhoValidated(db *gorm.DB, subjectID int) *gorm.DB {
+	return db.
+		Table("projects").
+		Select("users.id").
+		Joins("JOIN users ON "+ProjectUserCondition).
+		Where("projects.subject_id = ?", subjectID).
+		Where(ValidatedProjectCondition)
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GORM can't create partitioned tables, so this one is
+// created by hand. Postgres needs the partition key to
+// be part of the primary key.
+func createLocationSessionsTable(db *gorm.DB) error {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS location_sessions (
+		id bigint NOT NULL,
+		user_id bigint,
+		login text,
+		host text,
+		campus_id bigint,
+		begin_at timestamptz NOT NULL,
+		end_at timestamptz,
+		PRIMARY KEY (id, begin_at)
+	) PARTITION BY RANGE (begin_at)`).Error
+	if err != nil {
+		return err
+	}
+	err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_location_sessions_user
+		ON location_sessions (user_id, begin_at)`).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`CREATE INDEX IF NOT EXISTS idx_location_sessions_host
+		ON location_sessions (campus_id, host, begin_at)`).Error
+}
+
+var (
+	existingPartitions   = make(map[string]bool)
+	existingPartitionsMu sync.Mutex
+)
+
+func sessionsPartitionName(month time.Time) string {
+	return fmt.Sprintf("location_sessions_%04d_%02d", month.Year(), month.Month())
+}
+
+// Creates the partition of location_sessions for
+// the month of the given time if it doesn't exist
+func EnsureSessionsPartition(db *gorm.DB, at time.Time) error {
+	at = at.UTC()
+	month := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	name := sessionsPartitionName(month)
+
+	existingPartitionsMu.Lock()
+	defer existingPartitionsMu.Unlock()
+	if existingPartitions[name] {
+		return nil
+	}
+
+	err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s
+		PARTITION OF location_sessions
+		FOR VALUES FROM ('%s') TO ('%s')`,
+		name,
+		month.Format(time.RFC3339),
+		month.AddDate(0, 1, 0).Format(time.RFC3339))).Error
+	if err == nil {
+		existingPartitions[name] = true
+	}
+	return err
+}
+
+// Drops the partitions of location_sessions
+// of months which ended before the given time
+func DropSessionsPartitionsBefore(db *gorm.DB, before time.Time) error {
+	var partitions []string
+	err := db.Raw(`SELECT child.relname FROM pg_inherits
+		JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE parent.relname = 'location_sessions'`).
+		Scan(&partitions).Error
+	if err != nil {
+		return err
+	}
+
+	before = before.UTC()
+	oldest := sessionsPartitionName(
+		time.Date(before.Year(), before.Month(), 1, 0, 0, 0, 0, time.UTC))
+
+	existingPartitionsMu.Lock()
+	defer existingPartitionsMu.Unlock()
+	for _, partition := range partitions {
+		// Names sort like the months they're for
+		if partition >= oldest {
+			continue
+		}
+		err = db.Exec("DROP TABLE IF EXISTS " + partition).Error
+		if err != nil {
+			return err
+		}
+		delete(existingPartitions, partition)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package evaluations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/api"
+	"github.com/demostanis/42evaluators/internal/cursus"
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+var cursus21Begin, _ = time.Parse(time.RFC3339, "2019-07-29T08:45:17.896Z")
+
+const maxConcurrentFetches = 50
+
+func getParams() map[string]string {
+	cursusIDs := make([]string, 0)
+	for _, cursusID := range cursus.Enabled() {
+		cursusIDs = append(cursusIDs, strconv.Itoa(cursusID))
+	}
+
+	params := make(map[string]string)
+	params["filter[cursus_id]"] = strings.Join(cursusIDs, ",")
+	return params
+}
+
+func GetEvaluations(ctx context.Context, db *gorm.DB, errstream chan error) {
+	evaluations, err := api.DoPaginated[[]models.Evaluation](
+		api.NewRequest("/v2/scale_teams").
+			WithMaxConcurrentFetches(maxConcurrentFetches).
+			WithParams(getParams()).
+			SinceLastFetch(db, cursus21Begin).
+			Authenticated())
+	if err != nil {
+		errstream <- err
+		return
+	}
+
+	start := time.Now()
+
+	for {
+		evaluation, err := (<-evaluations)()
+		if err != nil {
+			errstream <- fmt.Errorf("error while fetching evaluations: %w", err)
+			continue
+		}
+		if evaluation == nil {
+			break
+		}
+		if evaluation.EvaluatorID == 0 {
+			continue
+		}
+
+		err = db.Save(&evaluation).Error
+		if err != nil {
+			errstream <- err
+		}
+	}
+
+	fmt.Printf("took %.2f minutes to fetch all evaluations\n",
+		time.Since(start).Minutes())
+}
@@ -1,6 +1,19 @@
 package models
 
+import "time"
+
 type Campus struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	// e.g. Europe/Paris
+	TimeZone string `json:"time_zone"`
+}
+
+// Returns the campus' time zone, or UTC if it's unknown
+func (campus Campus) Location() *time.Location {
+	location, err := time.LoadLocation(campus.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
 }
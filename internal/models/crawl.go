@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// The state of a paginated crawl over a range[updated_at]
+// window, so that it can be resumed if the process dies
+// before every page has been fetched.
+type CrawlCheckpoint struct {
+	Job string `gorm:"primaryKey"`
+	// Everything updated before this has already been fetched
+	Watermark time.Time
+	// The end of the window currently being fetched, or
+	// the zero time if no crawl is in progress
+	WindowEnd time.Time
+	// How many things are in the window
+	Total int
+}
+
+// The window of a crawl is split in ranges of IDs, which
+// are fetched concurrently, each by ascending IDs
+type CrawlRange struct {
+	Job     string `gorm:"primaryKey"`
+	FirstID int    `gorm:"primaryKey"`
+	LastID  int
+	// Everything up to this ID has already been fetched and saved
+	Cursor int
+}
+
+func (crawlRange CrawlRange) Done() bool {
+	return crawlRange.Cursor >= crawlRange.LastID
+}
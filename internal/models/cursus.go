@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	// The 42 cursus, the one most things are about
+	MainCursusID = 21
+	// The C piscine, that people do before joining it
+	PiscineCursusID = 9
+)
+
+type Cursus struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type CursusUser struct {
+	UserID       int `gorm:"primaryKey"`
+	CursusID     int `gorm:"primaryKey"`
+	Level        float64
+	BeginAt      time.Time
+	EndAt        time.Time
+	Grade        string
+	BlackholedAt time.Time
+	// Logtime between BeginAt and EndAt. This is only
+	// computed for piscines, since it would require way
+	// too many requests for other cursuses
+	Logtime time.Duration
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EvaluationRaw struct {
+	ID        int    `json:"id"`
+	FinalMark int    `json:"final_mark"`
+	BeginAt   string `json:"begin_at"`
+	FilledAt  string `json:"filled_at"`
+	// This is usually a user, but can also be a
+	// string such as "supervisor" or "invisible"
+	Corrector json.RawMessage `json:"corrector"`
+	Flag      struct {
+		Name     string `json:"name"`
+		Positive bool   `json:"positive"`
+	} `json:"flag"`
+	Team struct {
+		ID        int `json:"id"`
+		ProjectID int `json:"project_id"`
+	} `json:"team"`
+	Feedbacks []struct {
+		Rating int `json:"rating"`
+	} `json:"feedbacks"`
+	Scale struct {
+		// In seconds
+		Duration int `json:"duration"`
+	} `json:"scale"`
+}
+
+// An evaluation, named scale team by the intra
+type Evaluation struct {
+	ID           int
+	EvaluatorID  int
+	Evaluator    User
+	TeamID       int
+	SubjectID    int
+	Subject      Subject
+	FinalMark    int
+	FlagName     string
+	FlagPositive bool
+	// The average rating the evaluated gave,
+	// or nil if they didn't give feedback yet
+	FeedbackRating *float64
+	Duration       time.Duration
+	BeginAt        time.Time
+	FilledAt       time.Time
+}
+
+func (evaluation *Evaluation) UnmarshalJSON(data []byte) error {
+	var evaluationRaw EvaluationRaw
+
+	if err := json.Unmarshal(data, &evaluationRaw); err != nil {
+		return err
+	}
+
+	var corrector struct {
+		ID int `json:"id"`
+	}
+	// Ignore errors, since we don't care
+	// about non-user correctors
+	_ = json.Unmarshal(evaluationRaw.Corrector, &corrector)
+
+	evaluation.ID = evaluationRaw.ID
+	evaluation.EvaluatorID = corrector.ID
+	evaluation.TeamID = evaluationRaw.Team.ID
+	evaluation.SubjectID = evaluationRaw.Team.ProjectID
+	evaluation.FinalMark = evaluationRaw.FinalMark
+	evaluation.FlagName = evaluationRaw.Flag.Name
+	evaluation.FlagPositive = evaluationRaw.Flag.Positive
+	evaluation.BeginAt, _ = time.Parse(DateFormat, evaluationRaw.BeginAt)
+	evaluation.FilledAt, _ = time.Parse(DateFormat, evaluationRaw.FilledAt)
+
+	if len(evaluationRaw.Feedbacks) > 0 {
+		var total float64
+		for _, feedback := range evaluationRaw.Feedbacks {
+			total += float64(feedback.Rating)
+		}
+		rating := total / float64(len(evaluationRaw.Feedbacks))
+		evaluation.FeedbackRating = &rating
+	}
+
+	// How long it actually took, if we know it
+	if !evaluation.BeginAt.IsZero() && !evaluation.FilledAt.IsZero() &&
+		evaluation.FilledAt.After(evaluation.BeginAt) {
+		evaluation.Duration = evaluation.FilledAt.Sub(evaluation.BeginAt)
+	} else {
+		evaluation.Duration = time.Duration(evaluationRaw.Scale.Duration) * time.Second
+	}
+
+	return nil
+}
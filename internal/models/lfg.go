@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// A "looking for group" announcement for a subject
+type LFGEntry struct {
+	ID        int     `json:"id"`
+	UserID    int     `gorm:"index" json:"-"`
+	User      User    `json:"-"`
+	SubjectID int     `gorm:"index" json:"subject_id"`
+	Subject   Subject `json:"-"`
+	// The author's campus when posting
+	CampusID int `gorm:"index" json:"campus_id"`
+	// When the author can work, e.g. "weekends"
+	Availability string     `json:"availability"`
+	Note         string     `json:"note"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
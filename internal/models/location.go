@@ -6,6 +6,7 @@ type Location struct {
 	Login    string `json:"login"`
 	Host     string `json:"host"`
 	CampusID int    `json:"campus_id"`
+	BeginAt  string `json:"begin_at"`
 	EndAt    string `json:"end_at"`
 	Image    string
 }
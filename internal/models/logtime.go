@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// How long a user was logged in during a day,
+// in the time zone of their campus
+type LogtimeDay struct {
+	UserID  int       `gorm:"primaryKey"`
+	Date    time.Time `gorm:"primaryKey;type:date"`
+	Logtime time.Duration
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// How many seats of a cluster were taken at some point
+type ClusterOccupancy struct {
+	ClusterID int       `gorm:"primaryKey"`
+	At        time.Time `gorm:"primaryKey"`
+	Occupied  int
+	Seats     int
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type PlanProject struct {
+	// The subject's name, or e.g. "+ 4200 XP"
+	// when the XP was entered manually
+	Name           string `json:"name"`
+	XP             int    `json:"xp"`
+	Mark           int    `json:"mark"`
+	CoalitionBonus bool   `json:"coalition_bonus"`
+}
+
+// A list of projects a user plans to do,
+// saved from the XP calculator
+type CalculatorPlan struct {
+	ID        int           `json:"id"`
+	UserID    int           `gorm:"index" json:"-"`
+	Name      string        `json:"name"`
+	CursusID  int           `json:"cursus_id"`
+	Level     float64       `json:"level"`
+	Projects  []PlanProject `gorm:"serializer:json" json:"projects"`
+	CreatedAt time.Time     `json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A user's correction points and wallets, recorded
+// each time a crawl sees them change
+type PointsHistory struct {
+	ID               int
+	UserID           int `gorm:"index;uniqueIndex:idx_points_history_user_at"`
+	CorrectionPoints int
+	Wallets          int
+	// Truncated to the hour, since the same user can be seen
+	// by the crawls of several cursuses at the same time
+	At time.Time `gorm:"uniqueIndex:idx_points_history_user_at"`
+}
+
+// Must be called before UpdateFields, which overwrites
+// the previous values with the freshly fetched ones
+func (user *User) RecordPointsChange(db *gorm.DB) error {
+	var last PointsHistory
+	err := db.
+		Session(&gorm.Session{}).
+		Model(&PointsHistory{}).
+		Where("user_id = ?", user.ID).
+		Order("at DESC").
+		First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil &&
+		last.CorrectionPoints == user.CorrectionPoints &&
+		last.Wallets == user.Wallets {
+		return nil
+	}
+
+	return db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "at"}},
+			DoUpdates: clause.AssignmentColumns([]string{"correction_points", "wallets"}),
+		}).
+		Create(&PointsHistory{
+			UserID:           user.ID,
+			CorrectionPoints: user.CorrectionPoints,
+			Wallets:          user.Wallets,
+			At:               time.Now().UTC().Truncate(time.Hour),
+		}).Error
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type TeamUser struct {
 	TeamID int  `gorm:"primaryKey"`
 	UserID int  `gorm:"primaryKey" json:"id"`
@@ -11,10 +13,21 @@ type Team struct {
 	// this musn't be a gorm.Model, because GORM is so
 	// fucking drunk and will put two ids in INSERT statements,
 	// making the DB complain that there are two fucking ids.
-	ID        int        `json:"id"`
-	Name      string     `json:"name"`
-	Users     []TeamUser `json:"users"`
+	ID    int        `json:"id"`
+	Name  string     `json:"name"`
+	Users []TeamUser `json:"users"`
+	// Teams are shared between the projects of their members,
+	// this is only one of them. Use Project.Teams to get the
+	// teams of a project.
 	ProjectID int
+	Status    string `json:"status"`
+	// These are nil until the team gets evaluated
+	FinalMark *int  `json:"final_mark"`
+	Validated *bool `json:"validated?"`
+
+	CreatedAt time.Time `json:"created_at"`
+	LockedAt  time.Time `json:"locked_at"`
+	ClosedAt  time.Time `json:"closed_at"`
 }
 
 type Subject struct {
@@ -32,10 +45,43 @@ type Project struct {
 	CursusIDs     []int  `gorm:"-" json:"cursus_ids"`
 	FinalMark     int    `json:"final_mark"`
 	Status        string `json:"status"`
-	Teams         []Team `gorm:"foreignKey:ProjectID" json:"teams"`
-	CurrentTeamID int    `gorm:"-" json:"current_team_id"`
-	ActiveTeam    int
+	Validated     bool   `json:"validated?"`
+	Teams         []Team `gorm:"many2many:project_teams" json:"teams"`
+	CurrentTeamID int    `json:"current_team_id"`
+	// Unlike Teams, this is there even when the team
+	// is linked to another member's project
+	CurrentTeam Team `gorm:"foreignKey:CurrentTeamID" json:"-"`
+	// The index of the current team in Teams, only
+	// useful for projects fetched before CurrentTeamID
+	ActiveTeam int
+	// How many times the project was retried
+	Occurrence int       `json:"occurrence"`
+	MarkedAt   time.Time `json:"marked_at"`
+	// Not named UpdatedAt, since GORM
+	// would overwrite it when saving
+	LastActivityAt time.Time `gorm:"index" json:"updated_at"`
+
+	// Projects fetched before we supported other
+	// cursuses were all from the main one
+	CursusID int `gorm:"default:21"`
 
-	SubjectID int
+	// Only set for projects fetched after this field was
+	// added, older ones need to go through their teams
+	UserID      int `gorm:"index"`
+	ProjectUser struct {
+		ID int `json:"id"`
+	} `gorm:"-" json:"user"`
+
+	SubjectID int     `gorm:"index"`
 	Subject   Subject `json:"project"`
 }
+
+func (project *Project) GetCurrentTeam() *Team {
+	if project.CurrentTeam.ID != 0 {
+		return &project.CurrentTeam
+	}
+	if project.ActiveTeam < len(project.Teams) {
+		return &project.Teams[project.ActiveTeam]
+	}
+	return nil
+}
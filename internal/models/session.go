@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// A location which ended. The table is partitioned by
+// the month of BeginAt, see database.EnsureSessionsPartition.
+type LocationSession struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	UserID   int       `json:"user_id"`
+	Login    string    `json:"login"`
+	Host     string    `json:"host"`
+	CampusID int       `json:"campus_id"`
+	BeginAt  time.Time `gorm:"primaryKey" json:"begin_at"`
+	EndAt    time.Time `json:"end_at"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type SlotRaw struct {
+	ID      int    `json:"id"`
+	BeginAt string `json:"begin_at"`
+	EndAt   string `json:"end_at"`
+	// The intra only tells who owns a slot to its owner,
+	// and gives "invisible" to everyone else
+	User      json.RawMessage `json:"user"`
+	ScaleTeam json.RawMessage `json:"scale_team"`
+}
+
+// An evaluation slot which hasn't been booked yet
+type Slot struct {
+	ID      int
+	UserID  int
+	BeginAt time.Time
+	EndAt   time.Time
+	// Whether someone already booked it
+	Booked bool `gorm:"-"`
+}
+
+func (slot *Slot) UnmarshalJSON(data []byte) error {
+	var slotRaw SlotRaw
+
+	if err := json.Unmarshal(data, &slotRaw); err != nil {
+		return err
+	}
+
+	var user struct {
+		ID int `json:"id"`
+	}
+	_ = json.Unmarshal(slotRaw.User, &user)
+
+	slot.ID = slotRaw.ID
+	slot.UserID = user.ID
+	slot.BeginAt, _ = time.Parse(DateFormat, slotRaw.BeginAt)
+	slot.EndAt, _ = time.Parse(DateFormat, slotRaw.EndAt)
+	slot.Booked = len(slotRaw.ScaleTeam) > 0 &&
+		string(slotRaw.ScaleTeam) != "null"
+	return nil
+}
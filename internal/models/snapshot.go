@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// A user's level and ranks in a cursus at the end of a day.
+// Ranks are by level, among real users of the cursus.
+type LevelSnapshot struct {
+	UserID   int       `gorm:"primaryKey"`
+	CursusID int       `gorm:"primaryKey"`
+	Date     time.Time `gorm:"primaryKey;type:date"`
+	Level    float64
+	// Within the user's campus
+	CampusRank int
+	// Within the users of their campus who began the
+	// cursus the same month as them
+	PromoRank  int
+	GlobalRank int
+}
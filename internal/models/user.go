@@ -15,9 +15,9 @@ const (
 	DateFormat            = time.RFC3339
 )
 
-type CursusUser struct {
+type CursusUserRaw struct {
 	// We cannot use our User struct since that would
-	// recurse indefinitely (CursusUser->User->CursusUser->User->...)
+	// recurse indefinitely (CursusUserRaw->User->CursusUserRaw->User->...)
 	// because of our custom UnmarshalJSON below
 	User struct {
 		ID          int    `json:"id"`
@@ -33,16 +33,21 @@ type CursusUser struct {
 		CorrectionPoints int `json:"correction_point"`
 		Wallets          int `json:"wallet"`
 	} `json:"user"`
+	CursusID     int     `json:"cursus_id"`
 	Level        float64 `json:"level"`
 	BlackholedAt string  `json:"blackholed_at"`
 	BeginAt      string  `json:"begin_at"`
+	EndAt        string  `json:"end_at"`
+	Grade        string  `json:"grade"`
 }
 
 type User struct {
-	ID               int
-	Login            string
-	DisplayName      string
-	IsStaff          bool
+	ID          int
+	Login       string
+	DisplayName string
+	IsStaff     bool
+	// These are the ones of the main cursus, see
+	// CursusUser for the ones of other cursuses
 	BlackholedAt     time.Time
 	BeginAt          time.Time
 	CorrectionPoints int
@@ -52,7 +57,25 @@ type User struct {
 	ImageLinkSmall string
 	IsTest         bool
 	Level          float64
+	// Logtime since the start of the day, week and
+	// month, in the time zone of the user's campus
+	DailyLogtime   time.Duration
 	WeeklyLogtime  time.Duration
+	MonthlyLogtime time.Duration
+	// Since we started recording LogtimeDays
+	TotalLogtime time.Duration
+	// Users who weren't returned by the last complete
+	// crawl of their campus (they left the cursus,
+	// were anonymized, transferred...)
+	IsInactive      bool
+	LastSeenInCrawl time.Time
+	// When their open evaluation slots were last fetched
+	SlotsFetchedAt time.Time
+	// When someone last looked for them as an evaluator
+	SlotsWantedAt time.Time
+	// Set by users who don't want to be found
+	// on the clusters map or with /api/whereis
+	HideLocation bool
 
 	CoalitionID int
 	Coalition   Coalition
@@ -60,10 +83,13 @@ type User struct {
 	Title       Title
 	CampusID    int
 	Campus      Campus
+
+	// The cursus this user was fetched from
+	Cursus CursusUser `gorm:"-"`
 }
 
 func (user *User) UnmarshalJSON(data []byte) error {
-	var cursusUser CursusUser
+	var cursusUser CursusUserRaw
 
 	if err := json.Unmarshal(data, &cursusUser); err != nil {
 		return err
@@ -73,17 +99,29 @@ func (user *User) UnmarshalJSON(data []byte) error {
 	user.Login = cursusUser.User.Login
 	user.DisplayName = cursusUser.User.DisplayName
 	user.IsStaff = cursusUser.User.IsStaff
-	user.BlackholedAt, _ = time.Parse(DateFormat, cursusUser.BlackholedAt)
 	user.CorrectionPoints = cursusUser.User.CorrectionPoints
-	user.BeginAt, _ = time.Parse(DateFormat, cursusUser.BeginAt)
 	user.Wallets = cursusUser.User.Wallets
 
+	user.Cursus = CursusUser{
+		UserID:   cursusUser.User.ID,
+		CursusID: cursusUser.CursusID,
+		Level:    math.Round(cursusUser.Level*100) / 100,
+		Grade:    cursusUser.Grade,
+	}
+	user.Cursus.BlackholedAt, _ = time.Parse(DateFormat, cursusUser.BlackholedAt)
+	user.Cursus.BeginAt, _ = time.Parse(DateFormat, cursusUser.BeginAt)
+	user.Cursus.EndAt, _ = time.Parse(DateFormat, cursusUser.EndAt)
+	if user.Cursus.CursusID == MainCursusID {
+		user.Level = user.Cursus.Level
+		user.BlackholedAt = user.Cursus.BlackholedAt
+		user.BeginAt = user.Cursus.BeginAt
+	}
+
 	user.ImageLinkSmall = cursusUser.User.Image.Versions.Small
 	if user.ImageLinkSmall == "" {
 		user.ImageLinkSmall = DefaultImageLinkSmall
 	}
 
-	user.Level = math.Round(cursusUser.Level*100) / 100
 	user.ImageLink = cursusUser.User.Image.Link
 	if user.ImageLink == "" {
 		user.ImageLink = DefaultImageLink
@@ -109,21 +147,32 @@ func (user *User) CreateIfNeeded(db *gorm.DB) error {
 }
 
 func (user *User) UpdateFields(db *gorm.DB) error {
-	return db.
-		Where("id = ?", user.ID).
-		Model(&User{}).Updates(map[string]any{
+	fields := map[string]any{
 		"ID":               user.ID,
 		"Login":            user.Login,
 		"DisplayName":      user.DisplayName,
 		"IsStaff":          user.IsStaff,
-		"BlackholedAt":     user.BlackholedAt,
 		"CorrectionPoints": user.CorrectionPoints,
 		"ImageLink":        user.ImageLink,
 		"ImageLinkSmall":   user.ImageLinkSmall,
-		"Level":            user.Level,
-		"BeginAt":          user.BeginAt,
 		"Wallets":          user.Wallets,
-	}).Error
+		"LastSeenInCrawl":  time.Now().UTC(),
+	}
+	if user.Cursus.CursusID == MainCursusID {
+		fields["BlackholedAt"] = user.BlackholedAt
+		fields["Level"] = user.Level
+		fields["BeginAt"] = user.BeginAt
+	}
+	return db.
+		Where("id = ?", user.ID).
+		Model(&User{}).Updates(fields).Error
+}
+
+func (user *User) SaveCursus(db *gorm.DB) error {
+	if user.Cursus.CursusID == 0 {
+		return errors.New("user was not fetched from a cursus")
+	}
+	return db.Save(&user.Cursus).Error
 }
 
 func (user *User) YesItsATestAccount(db *gorm.DB) error {
@@ -174,11 +223,29 @@ func (user *User) SetTitle(title Title, db *gorm.DB) error {
 		}).Error
 }
 
-func (user *User) SetWeeklyLogtime(logtime time.Duration, db *gorm.DB) error {
-	user.WeeklyLogtime = logtime
+func (user *User) SetLogtimes(
+	daily time.Duration,
+	weekly time.Duration,
+	monthly time.Duration,
+	db *gorm.DB,
+) error {
+	user.DailyLogtime = daily
+	user.WeeklyLogtime = weekly
+	user.MonthlyLogtime = monthly
+	return db.Model(&User{}).
+		Where("id = ?", user.ID).
+		Updates(map[string]any{
+			"DailyLogtime":   daily,
+			"WeeklyLogtime":  weekly,
+			"MonthlyLogtime": monthly,
+		}).Error
+}
+
+func (user *User) SetHideLocation(hide bool, db *gorm.DB) error {
+	user.HideLocation = hide
 	return db.Model(&User{}).
 		Where("id = ?", user.ID).
 		Updates(map[string]any{
-			"WeeklyLogtime": logtime,
+			"HideLocation": hide,
 		}).Error
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	UserDeactivated = "deactivated"
+	UserReactivated = "reactivated"
+)
+
+// Recorded each time the users reconciliation
+// marks someone as inactive, or active again.
+type UserChange struct {
+	ID     int
+	UserID int
+	User   User
+	Change string
+	At     time.Time
+}
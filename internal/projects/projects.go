@@ -7,26 +7,49 @@ import (
 	"io"
 	"math"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/demostanis/42evaluators/internal/api"
+	"github.com/demostanis/42evaluators/internal/cursus"
 	"github.com/demostanis/42evaluators/internal/models"
 	"gorm.io/gorm"
 )
 
 var cursus21Begin, _ = time.Parse(time.RFC3339, "2019-07-29T08:45:17.896Z")
 
-const maxConcurrentFetches = 100
+const (
+	maxConcurrentFetches = 100
+	// The intra doesn't return more than that per page
+	projectsPerRefetch = 100
+)
+
+var projectsWithoutUserRefetched = false
+
+// A line drawn in the Holy Graph, from
+// a project to the ones it unlocks
+type ProjectLink struct {
+	Points [][2]float64 `json:"points"`
+}
 
 type ProjectData struct {
-	X          float64 `json:"x"`
-	Y          float64 `json:"y"`
-	ProjectID  int     `json:"project_id"`
-	Difficulty int     `json:"difficulty"`
+	X          float64       `json:"x"`
+	Y          float64       `json:"y"`
+	ProjectID  int           `json:"project_id"`
+	Difficulty int           `json:"difficulty"`
+	Name       string        `json:"name"`
+	Kind       string        `json:"kind"`
+	Links      []ProjectLink `json:"by"`
 }
 
 var allProjectData []ProjectData
 
+// Returns every project of the Holy Graph
+func GetProjectData() []ProjectData {
+	return allProjectData
+}
+
 func OpenProjectData() error {
 	file, err := os.Open("assets/project_data.json")
 	if err != nil {
@@ -67,10 +90,16 @@ func setPositionInGraph(
 
 func prepareProjectForDB(db *gorm.DB, project *models.Project) {
 	project.SubjectID = project.Subject.ID
+	project.CursusID = project.CursusIDs[0]
+	project.UserID = project.ProjectUser.ID
 
 	for i := range project.Teams {
 		team := &project.Teams[i]
-		team.ProjectID = i
+		// Teams are shared between the projects of their
+		// members, so this ends up being the one of the
+		// last member we fetched. project_teams links
+		// them to every project.
+		team.ProjectID = project.ID
 
 		if team.ID == project.CurrentTeamID {
 			project.ActiveTeam = i
@@ -85,7 +114,73 @@ func prepareProjectForDB(db *gorm.DB, project *models.Project) {
 	setPositionInGraph(db, &project.Subject)
 }
 
+func saveProject(db *gorm.DB, project *models.Project) error {
+	if len(project.CursusIDs) > 0 && cursus.IsEnabled(project.CursusIDs[0]) &&
+		len(project.Teams) > 0 && len(project.Teams[0].Users) > 0 {
+		prepareProjectForDB(db, project)
+		return db.
+			Session(&gorm.Session{FullSaveAssociations: true}).
+			Save(project).Error
+	}
+	return nil
+}
+
+// Projects fetched before their user, validation, occurrence
+// and marking date were stored have no user. Only those are
+// fetched again, by batches of IDs, instead of crawling every
+// project since the beginning of the cursus. This is done once
+// per run, since projects deleted from the intra stay that way.
+func refetchProjectsWithoutUser(db *gorm.DB, errstream chan error) {
+	if projectsWithoutUserRefetched {
+		return
+	}
+
+	var projectIDs []int
+	err := db.
+		Model(&models.Project{}).
+		Where("user_id = 0").
+		Order("id").
+		Pluck("id", &projectIDs).Error
+	if err != nil {
+		errstream <- fmt.Errorf("failed to find projects without a user: %w", err)
+		return
+	}
+	if len(projectIDs) > 0 {
+		fmt.Printf("fetching %d projects without a user again...\n",
+			len(projectIDs))
+	}
+
+	for start := 0; start < len(projectIDs); start += projectsPerRefetch {
+		batch := projectIDs[start:min(start+projectsPerRefetch, len(projectIDs))]
+		ids := make([]string, 0, len(batch))
+		for _, id := range batch {
+			ids = append(ids, strconv.Itoa(id))
+		}
+
+		projects, err := api.Do[[]models.Project](
+			api.NewRequest("/v2/projects_users").
+				Authenticated().
+				WithParams(map[string]string{
+					"filter[id]": strings.Join(ids, ","),
+					"page[size]": strconv.Itoa(projectsPerRefetch),
+				}))
+		if err != nil {
+			errstream <- fmt.Errorf("failed to fetch projects without a user: %w", err)
+			return
+		}
+		for i := range *projects {
+			err = saveProject(db, &(*projects)[i])
+			if err != nil {
+				errstream <- err
+			}
+		}
+	}
+	projectsWithoutUserRefetched = true
+}
+
 func GetProjects(ctx context.Context, db *gorm.DB, errstream chan error) {
+	refetchProjectsWithoutUser(db, errstream)
+
 	projects, err := api.DoPaginated[[]models.Project](
 		api.NewRequest("/v2/projects_users").
 			WithMaxConcurrentFetches(maxConcurrentFetches).
@@ -93,6 +188,7 @@ func GetProjects(ctx context.Context, db *gorm.DB, errstream chan error) {
 			Authenticated())
 	if err != nil {
 		errstream <- err
+		return
 	}
 
 	start := time.Now()
@@ -107,15 +203,9 @@ func GetProjects(ctx context.Context, db *gorm.DB, errstream chan error) {
 			break
 		}
 
-		if len(project.CursusIDs) > 0 && project.CursusIDs[0] == 21 &&
-			len(project.Teams) > 0 && len(project.Teams[0].Users) > 0 {
-			prepareProjectForDB(db, project)
-			err = db.
-				Session(&gorm.Session{FullSaveAssociations: true}).
-				Save(&project).Error
-			if err != nil {
-				errstream <- err
-			}
+		err = saveProject(db, project)
+		if err != nil {
+			errstream <- err
 		}
 	}
 
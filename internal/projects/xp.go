@@ -14,27 +14,37 @@ type XPByLevel struct {
 
 var XPData []XPByLevel
 
-func OpenXPData() error {
-	file, err := os.Open("assets/xp.json")
+// Reads the XP required for each level from the
+// given file, which is usually assets/xp.json
+func ReadXPData(path string) ([]XPByLevel, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer file.Close()
 	bytes, err := io.ReadAll(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = json.Unmarshal(bytes, &XPData)
+	var xpData []XPByLevel
+	err = json.Unmarshal(bytes, &xpData)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for i := range XPData {
-		if i == len(XPData)-1 {
+	for i := range xpData {
+		if i == len(xpData)-1 {
 			break
 		}
-		levelXP := XPData[i].XP
-		nextLevelXP := XPData[i+1].XP
-		XPData[i].XPToNextLevel = nextLevelXP - levelXP
+		levelXP := xpData[i].XP
+		nextLevelXP := xpData[i+1].XP
+		xpData[i].XPToNextLevel = nextLevelXP - levelXP
 	}
-	return nil
+	return xpData, nil
+}
+
+func OpenXPData() error {
+	var err error
+	XPData, err = ReadXPData("assets/xp.json")
+	return err
 }
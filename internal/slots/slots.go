@@ -0,0 +1,123 @@
+package slots
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/api"
+	"github.com/demostanis/42evaluators/internal/models"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+const (
+	// Nobody books evaluations further than that
+	lookahead = time.Hour * 24 * 7
+	// Slots fetched more recently than that are reused
+	maxSlotsAge = time.Minute * 5
+	// Slots of users nobody looked for since then aren't fetched
+	maxWantedAge         = time.Hour
+	concurrentSlotsFetch = 5
+	maxSlotsPerUser      = 100
+)
+
+// The intra hides the owner of slots from everyone but
+// them, so /v2/slots can't tell whose slots are open.
+// They're fetched for each user instead.
+func fetchSlotsOf(db *gorm.DB, userID int) error {
+	now := time.Now().UTC()
+	params := make(map[string]string)
+	params["range[end_at]"] = fmt.Sprintf("%s,%s",
+		now.Format(time.RFC3339),
+		now.Add(lookahead).Format(time.RFC3339))
+	params["page[size]"] = strconv.Itoa(maxSlotsPerUser)
+
+	slots, err := api.Do[[]models.Slot](
+		api.NewRequest(fmt.Sprintf("/v2/users/%d/slots", userID)).
+			Authenticated().
+			WithParams(params))
+	if err != nil {
+		return err
+	}
+
+	openSlots := make([]models.Slot, 0)
+	for _, slot := range *slots {
+		if !slot.Booked {
+			slot.UserID = userID
+			openSlots = append(openSlots, slot)
+		}
+	}
+
+	// Slots get deleted when they're booked or
+	// cancelled, so we need to start from scratch
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where("user_id = ?", userID).
+			Delete(&models.Slot{}).Error
+		if err != nil {
+			return err
+		}
+		if len(openSlots) > 0 {
+			err = tx.Save(&openSlots).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.
+			Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("slots_fetched_at", now).Error
+	})
+}
+
+// Asks for the slots of these users to be kept fresh by GetSlots,
+// since fetching the slots of everyone would take way too long
+func Want(db *gorm.DB, userIDs []int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return db.
+		Model(&models.User{}).
+		Where("id IN ?", userIDs).
+		Update("slots_wanted_at", time.Now().UTC()).Error
+}
+
+// Fetches the open slots of the users someone looked
+// for recently, and whose slots weren't fetched recently
+func GetSlots(ctx context.Context, db *gorm.DB, errstream chan error) {
+	now := time.Now().UTC()
+	var staleUserIDs []int
+	err := db.
+		Model(&models.User{}).
+		Where("slots_wanted_at > ?", now.Add(-maxWantedAge)).
+		Where("slots_fetched_at < ?", now.Add(-maxSlotsAge)).
+		Pluck("id", &staleUserIDs).Error
+	if err != nil {
+		errstream <- err
+		return
+	}
+
+	var g errgroup.Group
+	g.SetLimit(concurrentSlotsFetch)
+	for _, userID := range staleUserIDs {
+		userID := userID
+		g.Go(func() error {
+			err := fetchSlotsOf(db, userID)
+			if err != nil {
+				errstream <- fmt.Errorf("error while fetching slots of %d: %w",
+					userID, err)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	err = db.
+		Where("end_at < ?", now).
+		Delete(&models.Slot{}).Error
+	if err != nil {
+		errstream <- fmt.Errorf("error while removing past slots: %w", err)
+	}
+}
@@ -3,8 +3,10 @@ package users
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -47,7 +49,8 @@ func (logtime *Logtime) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func calcWeeklyLogtime(logtime []Logtime) time.Duration {
+// Merges overlapping locations together
+func mergeLogtime(logtime []Logtime) []Logtime {
 	// Some people in some campuses sometimes are
 	// in multiples locations at once, so don't count
 	// their logtimes twice...
@@ -68,60 +71,190 @@ func calcWeeklyLogtime(logtime []Logtime) time.Duration {
 		previousLocation = location
 	}
 
-	var total time.Duration
+	merged := make([]Logtime, 0, len(logtime))
 	for _, location := range logtime {
+		if location.EndAt.After(location.BeginAt) {
+			merged = append(merged, location)
+		}
+	}
+	return merged
+}
+
+// Returns the total time the user was logged in
+func calcLogtime(logtime []Logtime) time.Duration {
+	var total time.Duration
+	for _, location := range mergeLogtime(logtime) {
 		total += location.EndAt.Sub(location.BeginAt)
 	}
 	total = total.Truncate(time.Minute)
 	return total
 }
 
-func GetLogtimes(
-	ctx context.Context,
-	db *gorm.DB,
-	errstream chan error,
-	wg *sync.WaitGroup,
-) {
-	wg.Add(1)
+// Only keeps the part of each location between begin
+// and end, or after begin if end is zero
+func clipLogtimeTo(logtime []Logtime, begin time.Time, end time.Time) []Logtime {
+	clipped := make([]Logtime, 0, len(logtime))
+	for _, location := range logtime {
+		if location.BeginAt.Before(begin) {
+			location.BeginAt = begin
+		}
+		if !end.IsZero() && location.EndAt.After(end) {
+			location.EndAt = end
+		}
+		if location.EndAt.After(location.BeginAt) {
+			clipped = append(clipped, location)
+		}
+	}
+	return clipped
+}
 
-	day := time.Hour * 24
-	currentDay := int(time.Now().UTC().Weekday() - 1)
-	daysSinceMonday := time.Duration(currentDay) * day
-	monday := time.Now().UTC().Add(-daysSinceMonday).Truncate(day)
-	sunday := monday.Add(day * 7)
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// Weeks start on Mondays, even though Go's start on Sundays
+func StartOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return StartOfDay(t).AddDate(0, 0, -daysSinceMonday)
+}
+
+func StartOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Returns the time zone of each user's campus
+func getUserLocations(db *gorm.DB) (map[int]*time.Location, error) {
+	var campuses []models.Campus
+	err := db.Find(&campuses).Error
+	if err != nil {
+		return nil, err
+	}
+	campusLocations := make(map[int]*time.Location)
+	for _, campus := range campuses {
+		campusLocations[campus.ID] = campus.Location()
+	}
 
+	var users []models.User
+	err = db.
+		Model(&models.User{}).
+		Select("id, campus_id").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	userLocations := make(map[int]*time.Location)
+	for _, user := range users {
+		if location, ok := campusLocations[user.CampusID]; ok {
+			userLocations[user.ID] = location
+		}
+	}
+	return userLocations, nil
+}
+
+// Locations which started a bit before the given range might still
+// overlap with it, so we need to fetch them too. Sessions are
+// closed by campuses every night anyway.
+const maxLocationDuration = 24 * time.Hour
+
+func fetchLogtimes(
+	request *api.APIRequest,
+	begin time.Time,
+	end time.Time,
+	// Called for each page which couldn't be fetched
+	onError func(error),
+) (map[int][]Logtime, error) {
 	params := make(map[string]string)
 	params["range[begin_at]"] = fmt.Sprintf("%s,%s",
-		monday.Format(time.RFC3339),
-		sunday.Format(time.RFC3339))
+		begin.Add(-maxLocationDuration).UTC().Format(time.RFC3339),
+		end.UTC().Format(time.RFC3339))
 
 	logtimes, err := api.DoPaginated[[]Logtime](
-		api.NewRequest("/v2/locations").
+		request.
 			Authenticated().
 			WithParams(params))
 	if err != nil {
-		errstream <- err
-		return
+		return nil, err
 	}
 
-	totalWeeklyLogtimes := make(map[int][]Logtime)
-
+	logtimesByUser := make(map[int][]Logtime)
 	for {
 		logtime, err := (<-logtimes)()
 		if err != nil {
-			errstream <- fmt.Errorf("error while fetching locations: %w", err)
+			onError(fmt.Errorf("error while fetching locations: %w", err))
 			continue
 		}
 		if logtime == nil {
 			break
 		}
 
-		totalWeeklyLogtimes[logtime.UserID] = append(
-			totalWeeklyLogtimes[logtime.UserID], *logtime)
+		logtimesByUser[logtime.UserID] = append(
+			logtimesByUser[logtime.UserID], *logtime)
 	}
+	return logtimesByUser, nil
+}
 
-	for userID, logtime := range totalWeeklyLogtimes {
-		weeklyLogtime := calcWeeklyLogtime(logtime)
+// Returns the logtime of the user between begin and end
+func GetLogtimeBetween(
+	userID int,
+	begin time.Time,
+	end time.Time,
+) (time.Duration, error) {
+	var pageErrs error
+	logtimes, err := fetchLogtimes(
+		api.NewRequest("/v2/locations").
+			WithParams(map[string]string{
+				"filter[user_id]": strconv.Itoa(userID),
+			}),
+		begin, end, func(err error) {
+			pageErrs = errors.Join(pageErrs, err)
+		})
+	if err != nil {
+		return 0, err
+	}
+	if pageErrs != nil {
+		return 0, pageErrs
+	}
+	return calcLogtime(clipLogtimeTo(logtimes[userID], begin, end)), nil
+}
+
+func GetLogtimes(
+	ctx context.Context,
+	db *gorm.DB,
+	errstream chan error,
+	wg *sync.WaitGroup,
+) {
+	wg.Add(1)
+	defer wg.Done()
+
+	userLocations, err := getUserLocations(db)
+	if err != nil {
+		errstream <- err
+		return
+	}
+
+	now := time.Now()
+	begin := getLogtimeFetchStart(now)
+
+	// Days missed because of this would never be fetched again
+	failed := false
+	logtimesByUser, err := fetchLogtimes(
+		api.NewRequest("/v2/locations"),
+		begin, now, func(err error) {
+			failed = true
+			errstream <- err
+		})
+	if err != nil {
+		errstream <- err
+		return
+	}
+
+	for userID, logtime := range logtimesByUser {
+		location, ok := userLocations[userID]
+		if !ok {
+			location = time.UTC
+		}
 
 		user := models.User{ID: userID}
 		err = user.CreateIfNeeded(db)
@@ -129,11 +262,27 @@ func GetLogtimes(
 			errstream <- err
 			continue
 		}
-		err = user.SetWeeklyLogtime(weeklyLogtime, db)
+
+		// The first day might be missing locations
+		// which started before we fetched
+		firstDay := StartOfDay(begin.In(location)).AddDate(0, 0, 1)
+		err = saveLogtimeDays(db, userID, splitLogtimeByDay(
+			clipLogtimeTo(logtime, firstDay, now), location))
 		if err != nil {
-			errstream <- err
+			failed = true
+			errstream <- fmt.Errorf("error while saving logtime history: %w", err)
 		}
 	}
+	if !failed {
+		logtimeBackfilled.Store(true)
+	}
 
-	wg.Done()
+	err = updateTotalLogtimes(db)
+	if err != nil {
+		errstream <- fmt.Errorf("error while updating total logtimes: %w", err)
+	}
+	err = updateRecentLogtimes(db, userLocations, now)
+	if err != nil {
+		errstream <- fmt.Errorf("error while updating recent logtimes: %w", err)
+	}
 }
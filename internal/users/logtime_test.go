@@ -0,0 +1,129 @@
+package users
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("could not load time zone %s: %v", name, err)
+	}
+	return location
+}
+
+func TestStartOfWeek(t *testing.T) {
+	paris := loadLocation(t, "Europe/Paris")
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{
+			name: "monday",
+			t:    time.Date(2024, 3, 25, 15, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "wednesday",
+			t:    time.Date(2024, 3, 27, 8, 30, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "sunday",
+			t:    time.Date(2024, 3, 24, 23, 59, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "sunday of a DST change",
+			t:    time.Date(2024, 3, 31, 12, 0, 0, 0, paris),
+			want: time.Date(2024, 3, 25, 0, 0, 0, 0, paris),
+		},
+		{
+			name: "across months",
+			t:    time.Date(2024, 5, 1, 10, 0, 0, 0, paris),
+			want: time.Date(2024, 4, 29, 0, 0, 0, 0, paris),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := StartOfWeek(test.t)
+			if !got.Equal(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClipLogtimeTo(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2024, 3, 25, hour, 0, 0, 0, time.UTC)
+	}
+	begin, end := at(10), at(18)
+
+	tests := []struct {
+		name    string
+		logtime []Logtime
+		end     time.Time
+		want    []Logtime
+	}{
+		{
+			name:    "inside the range",
+			logtime: []Logtime{{BeginAt: at(11), EndAt: at(12)}},
+			end:     end,
+			want:    []Logtime{{BeginAt: at(11), EndAt: at(12)}},
+		},
+		{
+			name:    "starting before the range",
+			logtime: []Logtime{{BeginAt: at(8), EndAt: at(12)}},
+			end:     end,
+			want:    []Logtime{{BeginAt: at(10), EndAt: at(12)}},
+		},
+		{
+			name:    "ending after the range",
+			logtime: []Logtime{{BeginAt: at(16), EndAt: at(20)}},
+			end:     end,
+			want:    []Logtime{{BeginAt: at(16), EndAt: at(18)}},
+		},
+		{
+			name:    "covering the range",
+			logtime: []Logtime{{BeginAt: at(6), EndAt: at(22)}},
+			end:     end,
+			want:    []Logtime{{BeginAt: at(10), EndAt: at(18)}},
+		},
+		{
+			name: "outside the range",
+			logtime: []Logtime{
+				{BeginAt: at(6), EndAt: at(9)},
+				{BeginAt: at(19), EndAt: at(21)},
+			},
+			end:  end,
+			want: []Logtime{},
+		},
+		{
+			name:    "without an end",
+			logtime: []Logtime{{BeginAt: at(16), EndAt: at(23)}},
+			end:     time.Time{},
+			want:    []Logtime{{BeginAt: at(16), EndAt: at(23)}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := clipLogtimeTo(test.logtime, begin, test.end)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if !got[i].BeginAt.Equal(test.want[i].BeginAt) ||
+					!got[i].EndAt.Equal(test.want[i].EndAt) {
+					t.Errorf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
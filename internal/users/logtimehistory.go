@@ -0,0 +1,173 @@
+package users
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How many weeks of logtime history to fetch the first time
+// locations are fetched, e.g. logtimebackfillweeks=52. Later
+// runs only fetch the days which can still change.
+const LogtimeBackfillVar = "logtimebackfillweeks"
+
+// Whether every day since the backfill start was saved,
+// which only happens once a whole fetch succeeded
+var logtimeBackfilled atomic.Bool
+
+func getLogtimeFetchStart(now time.Time) time.Time {
+	if logtimeBackfilled.Load() {
+		// Older days are already in logtime_days. This is
+		// yesterday in every time zone, since sessions that
+		// started then might still be open.
+		return StartOfDay(now.UTC()).AddDate(0, 0, -2)
+	}
+
+	// Weeks can start during the previous month, and the
+	// earliest campus is at most a day ahead of UTC
+	begin := StartOfMonth(now.UTC()).AddDate(0, 0, -8)
+
+	weeks, err := strconv.Atoi(os.Getenv(LogtimeBackfillVar))
+	if err == nil && weeks > 0 {
+		backfillStart := now.UTC().AddDate(0, 0, -7*weeks)
+		if backfillStart.Before(begin) {
+			begin = backfillStart
+		}
+	}
+	return begin
+}
+
+// Dates are stored at midnight UTC, whatever
+// the time zone of the day they're about
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Cuts locations at midnight in the given time zone
+// and returns the logtime of each of these days
+func splitLogtimeByDay(logtime []Logtime, location *time.Location) map[time.Time]time.Duration {
+	days := make(map[time.Time]time.Duration)
+	for _, session := range mergeLogtime(logtime) {
+		for cursor := session.BeginAt; cursor.Before(session.EndAt); {
+			dayStart := StartOfDay(cursor.In(location))
+			nextDay := dayStart.AddDate(0, 0, 1)
+			end := session.EndAt
+			if nextDay.Before(end) {
+				end = nextDay
+			}
+
+			days[dateOf(dayStart)] += end.Sub(cursor)
+			cursor = end
+		}
+	}
+	return days
+}
+
+func saveLogtimeDays(db *gorm.DB, userID int, days map[time.Time]time.Duration) error {
+	if len(days) == 0 {
+		return nil
+	}
+	rows := make([]models.LogtimeDay, 0, len(days))
+	for date, logtime := range days {
+		rows = append(rows, models.LogtimeDay{
+			UserID:  userID,
+			Date:    date,
+			Logtime: logtime,
+		})
+	}
+	return db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"logtime"}),
+		}).
+		Create(&rows).Error
+}
+
+func updateTotalLogtimes(db *gorm.DB) error {
+	return db.Exec(`UPDATE users SET total_logtime = totals.logtime
+		FROM (SELECT user_id, SUM(logtime) logtime
+			FROM logtime_days GROUP BY user_id) totals
+		WHERE totals.user_id = users.id`).Error
+}
+
+// Sets the daily, weekly and monthly logtimes of users from their
+// logtime history, and resets those of users who didn't come
+func updateRecentLogtimes(
+	db *gorm.DB,
+	userLocations map[int]*time.Location,
+	now time.Time,
+) error {
+	var days []models.LogtimeDay
+	err := db.
+		Where("date >= ?", dateOf(StartOfMonth(now.UTC()).AddDate(0, 0, -8))).
+		Find(&days).Error
+	if err != nil {
+		return err
+	}
+
+	type recentLogtimes struct {
+		daily, weekly, monthly time.Duration
+	}
+	logtimes := make(map[int]*recentLogtimes)
+	for _, day := range days {
+		location, ok := userLocations[day.UserID]
+		if !ok {
+			location = time.UTC
+		}
+		localNow := now.In(location)
+
+		logtime, ok := logtimes[day.UserID]
+		if !ok {
+			logtime = &recentLogtimes{}
+			logtimes[day.UserID] = logtime
+		}
+		if day.Date.Equal(dateOf(localNow)) {
+			logtime.daily += day.Logtime
+		}
+		if !day.Date.Before(dateOf(StartOfWeek(localNow))) {
+			logtime.weekly += day.Logtime
+		}
+		if !day.Date.Before(dateOf(StartOfMonth(localNow))) {
+			logtime.monthly += day.Logtime
+		}
+	}
+
+	for userID, logtime := range logtimes {
+		user := models.User{ID: userID}
+		err = user.SetLogtimes(
+			logtime.daily.Truncate(time.Minute),
+			logtime.weekly.Truncate(time.Minute),
+			logtime.monthly.Truncate(time.Minute),
+			db)
+		if err != nil {
+			return err
+		}
+	}
+
+	// People who didn't come since the start of the month
+	// would otherwise keep their logtime from back then
+	var staleUserIDs []int
+	err = db.
+		Model(&models.User{}).
+		Where("daily_logtime != 0 OR weekly_logtime != 0 OR monthly_logtime != 0").
+		Pluck("id", &staleUserIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, userID := range staleUserIDs {
+		if _, ok := logtimes[userID]; !ok {
+			user := models.User{ID: userID}
+			err = user.SetLogtimes(0, 0, 0, db)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package users
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestSplitLogtimeByDay(t *testing.T) {
+	paris := loadLocation(t, "Europe/Paris")
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		logtime  []Logtime
+		location *time.Location
+		want     map[time.Time]time.Duration
+	}{
+		{
+			name: "within a day",
+			logtime: []Logtime{{
+				BeginAt: time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC),
+				EndAt:   time.Date(2024, 3, 25, 17, 30, 0, 0, time.UTC),
+			}},
+			location: time.UTC,
+			want:     map[time.Time]time.Duration{date(3, 25): 8*time.Hour + 30*time.Minute},
+		},
+		{
+			name: "across midnight",
+			logtime: []Logtime{{
+				BeginAt: time.Date(2024, 3, 25, 22, 0, 0, 0, time.UTC),
+				EndAt:   time.Date(2024, 3, 26, 3, 0, 0, 0, time.UTC),
+			}},
+			location: time.UTC,
+			want: map[time.Time]time.Duration{
+				date(3, 25): 2 * time.Hour,
+				date(3, 26): 3 * time.Hour,
+			},
+		},
+		{
+			name: "across midnight in the campus' time zone",
+			logtime: []Logtime{{
+				// 22:00 to 02:00 in Paris
+				BeginAt: time.Date(2024, 3, 25, 21, 0, 0, 0, time.UTC),
+				EndAt:   time.Date(2024, 3, 26, 1, 0, 0, 0, time.UTC),
+			}},
+			location: paris,
+			want: map[time.Time]time.Duration{
+				date(3, 25): 2 * time.Hour,
+				date(3, 26): 2 * time.Hour,
+			},
+		},
+		{
+			name: "across a DST change",
+			logtime: []Logtime{{
+				// 22:00 CET to 04:00 CEST, clocks go
+				// forward at 02:00 on March 31st
+				BeginAt: time.Date(2024, 3, 30, 21, 0, 0, 0, time.UTC),
+				EndAt:   time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC),
+			}},
+			location: paris,
+			want: map[time.Time]time.Duration{
+				date(3, 30): 2 * time.Hour,
+				date(3, 31): 3 * time.Hour,
+			},
+		},
+		{
+			name: "across several days",
+			logtime: []Logtime{{
+				BeginAt: time.Date(2024, 3, 25, 12, 0, 0, 0, time.UTC),
+				EndAt:   time.Date(2024, 3, 27, 6, 0, 0, 0, time.UTC),
+			}},
+			location: time.UTC,
+			want: map[time.Time]time.Duration{
+				date(3, 25): 12 * time.Hour,
+				date(3, 26): 24 * time.Hour,
+				date(3, 27): 6 * time.Hour,
+			},
+		},
+		{
+			name: "overlapping locations",
+			logtime: []Logtime{
+				{
+					BeginAt: time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC),
+					EndAt:   time.Date(2024, 3, 25, 12, 0, 0, 0, time.UTC),
+				},
+				{
+					BeginAt: time.Date(2024, 3, 25, 11, 0, 0, 0, time.UTC),
+					EndAt:   time.Date(2024, 3, 25, 14, 0, 0, 0, time.UTC),
+				},
+			},
+			location: time.UTC,
+			want:     map[time.Time]time.Duration{date(3, 25): 5 * time.Hour},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitLogtimeByDay(test.logtime, test.location)
+			if !maps.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package users
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/api"
+	"github.com/demostanis/42evaluators/internal/cursus"
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+// Piscines last about a month, but this leaves
+// some time for late evaluations to come in
+const piscineTrackingPeriod = time.Hour * 24 * 45
+
+type piscine struct {
+	CampusID int
+	BeginAt  time.Time
+}
+
+func fetchPiscineLogtimes(
+	piscine piscine,
+	db *gorm.DB,
+	errstream chan error,
+) {
+	var cursusUsers []models.CursusUser
+	err := db.
+		Model(&models.CursusUser{}).
+		Joins("JOIN users ON users.id = cursus_users.user_id").
+		Where("cursus_users.cursus_id = ?", models.PiscineCursusID).
+		Where("users.campus_id = ?", piscine.CampusID).
+		Where("cursus_users.begin_at >= ?", piscine.BeginAt).
+		Find(&cursusUsers).Error
+	if err != nil {
+		errstream <- err
+		return
+	}
+	piscineux := make(map[int]models.CursusUser)
+	for _, cursusUser := range cursusUsers {
+		piscineux[cursusUser.UserID] = cursusUser
+	}
+
+	params := make(map[string]string)
+	params["filter[campus_id]"] = fmt.Sprint(piscine.CampusID)
+	params["range[begin_at]"] = fmt.Sprintf("%s,%s",
+		piscine.BeginAt.Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339))
+
+	logtimes, err := api.DoPaginated[[]Logtime](
+		api.NewRequest("/v2/locations").
+			Authenticated().
+			WithParams(params))
+	if err != nil {
+		errstream <- err
+		return
+	}
+
+	totalLogtimes := make(map[int][]Logtime)
+	for {
+		logtime, err := (<-logtimes)()
+		if err != nil {
+			errstream <- fmt.Errorf("error while fetching locations: %w", err)
+			continue
+		}
+		if logtime == nil {
+			break
+		}
+		if _, ok := piscineux[logtime.UserID]; ok {
+			totalLogtimes[logtime.UserID] = append(
+				totalLogtimes[logtime.UserID], *logtime)
+		}
+	}
+
+	for userID, logtime := range totalLogtimes {
+		// Only keeps the part of each location which
+		// happened while the user was doing the piscine
+		total := calcLogtime(clipLogtimeTo(logtime,
+			piscineux[userID].BeginAt, piscineux[userID].EndAt))
+		err = db.
+			Model(&models.CursusUser{}).
+			Where("user_id = ?", userID).
+			Where("cursus_id = ?", models.PiscineCursusID).
+			Update("logtime", total).Error
+		if err != nil {
+			errstream <- err
+		}
+	}
+}
+
+// Computes the total logtime of piscineux of piscines which
+// started recently. This needs users to be fetched first.
+func getPiscineLogtimes(db *gorm.DB, errstream chan error) {
+	if !cursus.IsEnabled(models.PiscineCursusID) {
+		return
+	}
+
+	var piscines []piscine
+	err := db.
+		Model(&models.CursusUser{}).
+		Select("users.campus_id, MIN(cursus_users.begin_at) begin_at").
+		Joins("JOIN users ON users.id = cursus_users.user_id").
+		Where("cursus_users.cursus_id = ?", models.PiscineCursusID).
+		Where("cursus_users.begin_at > ?",
+			time.Now().Add(-piscineTrackingPeriod)).
+		Group("users.campus_id").
+		Scan(&piscines).Error
+	if err != nil {
+		errstream <- err
+		return
+	}
+
+	for _, piscine := range piscines {
+		fetchPiscineLogtimes(piscine, db, errstream)
+	}
+}
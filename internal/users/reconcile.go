@@ -0,0 +1,78 @@
+package users
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+func recordChanges(tx *gorm.DB, userIDs []int, change string, at time.Time) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	changes := make([]models.UserChange, 0, len(userIDs))
+	for _, userID := range userIDs {
+		changes = append(changes, models.UserChange{
+			UserID: userID,
+			Change: change,
+			At:     at,
+		})
+	}
+	err := tx.Create(&changes).Error
+	if err != nil {
+		return err
+	}
+	return tx.
+		Model(&models.User{}).
+		Where("id IN ?", userIDs).
+		Update("is_inactive", change == models.UserDeactivated).Error
+}
+
+// Marks users of the given campuses who weren't seen since
+// crawlStart as inactive, and users who were seen again as
+// active. Only campuses which were fully crawled must be
+// passed, else we'd deactivate users whose page errored out.
+func reconcileUsers(db *gorm.DB, crawlStart time.Time, crawledCampuses []int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		var reactivated []int
+		err := tx.
+			Model(&models.User{}).
+			Where("is_inactive = true").
+			Where("last_seen_in_crawl >= ?", crawlStart).
+			Pluck("id", &reactivated).Error
+		if err != nil {
+			return err
+		}
+
+		var deactivated []int
+		if len(crawledCampuses) > 0 {
+			err = tx.
+				Model(&models.User{}).
+				Where("is_inactive = false").
+				Where("campus_id IN ?", crawledCampuses).
+				Where("last_seen_in_crawl < ?", crawlStart).
+				Pluck("id", &deactivated).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = recordChanges(tx, reactivated, models.UserReactivated, now)
+		if err != nil {
+			return err
+		}
+		err = recordChanges(tx, deactivated, models.UserDeactivated, now)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("reconciled users: %d deactivated, %d reactivated\n",
+			len(deactivated), len(reactivated))
+		return nil
+	})
+}
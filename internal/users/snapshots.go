@@ -0,0 +1,33 @@
+package users
+
+import (
+	"github.com/demostanis/42evaluators/internal/cursus"
+	"github.com/demostanis/42evaluators/internal/database"
+	"gorm.io/gorm"
+)
+
+// Records today's level and ranks of every user. Running
+// this again during the same day overwrites the snapshot,
+// so the last crawl of the day is the one which is kept.
+func takeLevelSnapshots(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO level_snapshots
+		(user_id, cursus_id, date, level, campus_rank, promo_rank, global_rank)
+		SELECT users.id, cursus_users.cursus_id, CURRENT_DATE, cursus_users.level,
+			RANK() OVER (PARTITION BY cursus_users.cursus_id, users.campus_id
+				ORDER BY cursus_users.level DESC),
+			RANK() OVER (PARTITION BY cursus_users.cursus_id, users.campus_id,
+				DATE_TRUNC('month', cursus_users.begin_at)
+				ORDER BY cursus_users.level DESC),
+			RANK() OVER (PARTITION BY cursus_users.cursus_id
+				ORDER BY cursus_users.level DESC)
+		FROM users
+		JOIN cursus_users ON cursus_users.user_id = users.id
+		WHERE `+database.OnlyRealUsersCondition+`
+		AND cursus_users.cursus_id IN ?
+		ON CONFLICT (user_id, cursus_id, date) DO UPDATE SET
+			level = EXCLUDED.level,
+			campus_rank = EXCLUDED.campus_rank,
+			promo_rank = EXCLUDED.promo_rank,
+			global_rank = EXCLUDED.global_rank`,
+		cursus.Enabled()).Error
+}
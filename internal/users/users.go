@@ -3,7 +3,6 @@ package users
 import (
 	"context"
 	"fmt"
-	"maps"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,14 +12,12 @@ import (
 
 	"github.com/demostanis/42evaluators/internal/api"
 	"github.com/demostanis/42evaluators/internal/campus"
+	"github.com/demostanis/42evaluators/internal/cursus"
 	"github.com/demostanis/42evaluators/internal/models"
 	"gorm.io/gorm"
 )
 
 var (
-	DefaultParams = map[string]string{
-		"filter[cursus_id]": "21",
-	}
 	ConcurrentCampusesFetch = int64(5)
 )
 
@@ -35,8 +32,16 @@ func WaitForUsers() {
 	}
 }
 
-func fetchOneCampus(ctx context.Context, campusID int, db *gorm.DB, errstream chan error) {
-	params := maps.Clone(DefaultParams)
+// Returns whether every page of the campus could be fetched
+func fetchOneCampus(
+	ctx context.Context,
+	cursusID int,
+	campusID int,
+	db *gorm.DB,
+	errstream chan error,
+) bool {
+	params := make(map[string]string)
+	params["filter[cursus_id]"] = strconv.Itoa(cursusID)
 	params["filter[campus_id]"] = strconv.Itoa(campusID)
 
 	users, err := api.DoPaginated[[]models.User](
@@ -45,14 +50,16 @@ func fetchOneCampus(ctx context.Context, campusID int, db *gorm.DB, errstream ch
 			WithParams(params))
 	if err != nil {
 		errstream <- err
-		return
+		return false
 	}
 
+	complete := true
 	var wg sync.WaitGroup
 	for {
 		user, err := (<-users)()
 		if err != nil {
 			errstream <- err
+			complete = false
 			continue
 		}
 		if user == nil {
@@ -70,11 +77,21 @@ func fetchOneCampus(ctx context.Context, campusID int, db *gorm.DB, errstream ch
 				errstream <- err
 				return
 			}
+			err = user.RecordPointsChange(db)
+			if err != nil {
+				errstream <- err
+				return
+			}
 			err = user.UpdateFields(db)
 			if err != nil {
 				errstream <- err
 				return
 			}
+			err = user.SaveCursus(db)
+			if err != nil {
+				errstream <- err
+				return
+			}
 			err = user.SetCampus(campusID, db)
 			if err != nil {
 				errstream <- err
@@ -82,6 +99,7 @@ func fetchOneCampus(ctx context.Context, campusID int, db *gorm.DB, errstream ch
 		}()
 	}
 	wg.Wait()
+	return complete
 }
 
 func GetUsers(ctx context.Context, db *gorm.DB, errstream chan error) {
@@ -98,25 +116,54 @@ func GetUsers(ctx context.Context, db *gorm.DB, errstream chan error) {
 	start := time.Now()
 	weights := semaphore.NewWeighted(ConcurrentCampusesFetch)
 
-	for _, campus := range campuses {
-		err := weights.Acquire(ctx, 1)
-		if err != nil {
-			errstream <- err
-			continue
-		}
-		wg.Add(1)
+	// A campus is only considered crawled if
+	// every one of its cursuses could be fetched
+	var failedCampusesMu sync.Mutex
+	failedCampuses := make(map[int]bool)
 
-		go func(campusID int) {
-			fetchOneCampus(ctx, campusID, db, errstream)
-			weights.Release(1)
-			wg.Done()
-		}(campus.ID)
+	for _, cursusID := range cursus.Enabled() {
+		for _, campus := range campuses {
+			err := weights.Acquire(ctx, 1)
+			if err != nil {
+				errstream <- err
+				continue
+			}
+			wg.Add(1)
+
+			go func(cursusID int, campusID int) {
+				if !fetchOneCampus(ctx, cursusID, campusID, db, errstream) {
+					failedCampusesMu.Lock()
+					failedCampuses[campusID] = true
+					failedCampusesMu.Unlock()
+				}
+				weights.Release(1)
+				wg.Done()
+			}(cursusID, campus.ID)
+		}
 	}
 
 	wg.Wait()
 	fmt.Printf("took %.2f minutes to fetch all users\n",
 		time.Since(start).Minutes())
 
+	var crawledCampuses []int
+	for _, campus := range campuses {
+		if !failedCampuses[campus.ID] {
+			crawledCampuses = append(crawledCampuses, campus.ID)
+		}
+	}
+
+	getPiscineLogtimes(db, errstream)
+
+	err := reconcileUsers(db, start, crawledCampuses)
+	if err != nil {
+		errstream <- fmt.Errorf("error while reconciling users: %w", err)
+	}
+	err = takeLevelSnapshots(db)
+	if err != nil {
+		errstream <- fmt.Errorf("error while taking level snapshots: %w", err)
+	}
+
 	if !waitForUsersClosed {
 		close(waitForUsers)
 		waitForUsersClosed = true
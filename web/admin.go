@@ -0,0 +1,32 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const userChangesPeriod = 30 * 24 * time.Hour
+
+func handleUserChanges(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var changes []models.UserChange
+		err := db.
+			Preload("User").
+			Preload("User.Campus").
+			Where("at > ?", time.Now().Add(-userChangesPeriod)).
+			Order("at DESC").
+			Find(&changes).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to list user changes: %w", err))
+			return
+		}
+
+		_ = templates.UserChanges(changes).
+			Render(r.Context(), w)
+	})
+}
@@ -53,7 +53,7 @@ func blackholeMap(db *gorm.DB) http.Handler {
 		}
 		var users []models.User
 		err := db.
-			Scopes(database.OnlyRealUsers()).
+			Scopes(database.OnlyMainCursusUsers()).
 			Scopes(database.WithCampus(campusID)).
 			Find(&users).Error
 		if err != nil {
@@ -1,22 +1,53 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
+	"github.com/demostanis/42evaluators/internal/calculator"
 	"github.com/demostanis/42evaluators/internal/database"
 	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/internal/projects"
 	"github.com/demostanis/42evaluators/web/templates"
 	"gorm.io/gorm"
 )
 
+// Projects which will give XP once they're done
+var activeProjectStatuses = []string{
+	"creating_group", "searching_a_group",
+	"in_progress", "waiting_for_correction",
+}
+
+func getLevel(db *gorm.DB, userID int, cursusID int) (float64, error) {
+	var level float64
+	err := db.
+		Model(&models.CursusUser{}).
+		Select("level").
+		Where("user_id = ?", userID).
+		Where("cursus_id = ?", cursusID).
+		Find(&level).Error
+	return level, err
+}
+
 func handleCalculator(db *gorm.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursusID := getCursusID(r)
+		cursuses, err := getAllCursuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get cursuses: %w", err))
+			return
+		}
+
 		var subjects []models.Subject
-		err := db.
+		err = db.
 			Model(&models.Subject{}).
 			Where(database.UnwantedSubjectsCondition).
 			Where("xp > 0").
+			Where("id IN (?)", db.
+				Model(&models.Project{}).
+				Select("subject_id").
+				Where("cursus_id = ?", cursusID)).
 			Order("position").
 			Find(&subjects).Error
 		if err != nil {
@@ -24,25 +55,118 @@ func handleCalculator(db *gorm.DB) http.Handler {
 			return
 		}
 
-		user := getLoggedInUser(r)
+		me := getLoggedInUser(r).them
+		level, err := getLevel(db, me.ID, cursusID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get user level: %w", err))
+			return
+		}
 
-		var level float64
+		var activeSubjects []models.Subject
 		err = db.
-			Model(&models.User{}).
-			Select("level").
-			Where("id = ?", user.them.ID).
-			Find(&level).Error
+			Model(&models.Subject{}).
+			Where("xp > 0").
+			Where("id IN (?)", db.
+				Model(&models.Project{}).
+				Select("projects.subject_id").
+				Where(projectsOf(db, me.ID)).
+				Where("projects.cursus_id = ?", cursusID).
+				Where("projects.status IN ?", activeProjectStatuses)).
+			Order("position").
+			Find(&activeSubjects).Error
 		if err != nil {
-			internalServerError(w, fmt.Errorf("failed to get user level: %w", err))
+			internalServerError(w, fmt.Errorf("failed to get active projects: %w", err))
+			return
+		}
+
+		plans, err := getPlanComparisons(db, me.ID, cursusID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get plans: %w", err))
 			return
 		}
 
-		// TODO: once we find a more optimized way of
-		// fetching the logged-in user's active projects,
-		// we should display them above others in the
-		// project select
+		_ = templates.Calculator(subjects, activeSubjects, level,
+			cursuses, cursusID, plans,
+		).Render(r.Context(), w)
+	})
+}
+
+type calculatorRequest struct {
+	// Defaults to the logged-in user's level
+	Level    *float64 `json:"level"`
+	CursusID int      `json:"cursus_id"`
+	Projects []struct {
+		// Either the subject, or its XP
+		SubjectID int `json:"subject_id"`
+		XP        int `json:"xp"`
+		// Defaults to 100
+		Mark           *int `json:"mark"`
+		CoalitionBonus bool `json:"coalition_bonus"`
+	} `json:"projects"`
+}
+
+func calculatorAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request calculatorRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err),
+				http.StatusBadRequest)
+			return
+		}
+		if request.CursusID == 0 {
+			request.CursusID = models.MainCursusID
+		}
+
+		var level float64
+		if request.Level != nil {
+			level = *request.Level
+		} else {
+			level, err = getLevel(db, getLoggedInUser(r).them.ID, request.CursusID)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to get user level: %w", err))
+				return
+			}
+		}
+
+		projectList := make([]calculator.Project, 0, len(request.Projects))
+		for _, project := range request.Projects {
+			xp := project.XP
+			if project.SubjectID != 0 {
+				err = db.
+					Model(&models.Subject{}).
+					Select("xp").
+					Where("id = ?", project.SubjectID).
+					Find(&xp).Error
+				if err != nil {
+					internalServerError(w, fmt.Errorf("failed to get subject xp: %w", err))
+					return
+				}
+			}
+			mark := 100
+			if project.Mark != nil {
+				mark = *project.Mark
+			}
+			projectList = append(projectList, calculator.Project{
+				XP:             xp,
+				Mark:           mark,
+				CoalitionBonus: project.CoalitionBonus,
+			})
+		}
+
+		err = calculator.Validate(projects.XPData, level, projectList)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		_ = templates.Calculator(subjects, level).
-			Render(r.Context(), w)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(
+			calculator.Calculate(projects.XPData, level, projectList))
 	})
 }
@@ -1,15 +1,11 @@
 package web
 
 import (
-	"cmp"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"slices"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/demostanis/42evaluators/internal/clusters"
 	"github.com/demostanis/42evaluators/internal/models"
@@ -18,70 +14,11 @@ import (
 	"gorm.io/gorm"
 )
 
-var allClusters []clusters.Cluster
-
-func OpenClustersData() error {
-	file, err := os.Open("assets/clusters.json")
-	if err != nil {
-		return err
-	}
-	bytes, err := io.ReadAll(file)
-	if err != nil {
-		return err
-	}
-	err = json.Unmarshal(bytes, &allClusters)
-	if err != nil {
-		return err
-	}
-	for i, c := range allClusters {
-		allClusters[i].DisplayName = fmt.Sprintf(
-			"%s - %s", c.Campus.Name, c.Name)
-	}
-	slices.SortFunc(allClusters, func(a, b clusters.Cluster) int {
-		return cmp.Compare(a.DisplayName, b.DisplayName)
-	})
-	return nil
-}
-
-func fetchSvg(cluster *clusters.Cluster) error {
-	var source io.Reader
-
-	if strings.HasPrefix(cluster.Image, "http") {
-		resp, err := http.Get(cluster.Image)
-		if err != nil {
-			return err
-		}
-		if resp.StatusCode != http.StatusOK {
-			cluster.Svg = "<p class=\"h-[90%] flex justify-center m-5 text-center items-center\">Cannot find this cluster map. It's likely that " +
-				"its campus' staff has modified it, and thus the link has changed. " +
-				"If you are part of this campus, please send the cluster SVG to " +
-				"@cgodard on Slack.</p>"
-			return nil
-		}
-		source = resp.Body
-		defer resp.Body.Close()
-	} else {
-		file, err := os.Open(cluster.Image)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		source = file
-	}
-
-	body, err := io.ReadAll(source)
-	if err != nil {
-		return err
-	}
-	cluster.Svg = strings.Replace(string(body), "<svg", "<svg width=\"100%\" height=\"90%\" class=\"p-5 absolute\"", 1)
-	return nil
-}
-
 func handleClusters() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defaultClusterID := 199
 		campusID := getLoggedInUser(r).them.CampusID
-		for _, cluster := range allClusters {
+		for _, cluster := range clusters.AllClusters {
 			if cluster.Campus.ID == campusID {
 				defaultClusterID = cluster.ID
 				break
@@ -98,26 +35,36 @@ func handleClusters() http.Handler {
 			return
 		}
 		found := false
-		for _, cluster := range allClusters {
+		for _, cluster := range clusters.AllClusters {
 			if cluster.ID == clusterID {
 				selectedCluster = cluster
 				found = true
 			}
 		}
 		if !found {
-			selectedCluster = allClusters[defaultClusterID]
+			selectedCluster = clusters.AllClusters[defaultClusterID]
 		}
 		if selectedCluster.Svg == "" {
-			_ = fetchSvg(&selectedCluster)
+			_ = clusters.FetchSvg(&selectedCluster)
 		}
 
-		_ = templates.ClustersMap(allClusters, selectedCluster).
+		_ = templates.ClustersMap(clusters.AllClusters, selectedCluster).
 			Render(r.Context(), w)
 	})
 }
 
 var upgrader = websocket.Upgrader{}
 
+const (
+	pingInterval = 30 * time.Second
+	// Clients which don't answer pings in time are gone
+	pongWait  = 2 * pingInterval
+	writeWait = 10 * time.Second
+)
+
+// Shown on the seats of people who hid their location
+const hiddenUserImage = "/static/assets/hidden.svg"
+
 type Message struct {
 	ClusterID int `json:"cluster"`
 }
@@ -129,53 +76,52 @@ type Response struct {
 	Left  bool   `json:"left"`
 }
 
-func findCampusIDForCluster(clusterID int) int {
-	campusID := -1
-	for _, cluster := range allClusters {
-		if cluster.ID == clusterID {
-			campusID = cluster.Campus.ID
-		}
-	}
-	return campusID
-}
-
-var locationChans []chan models.Location
-
-func broadcastLocations() {
-	for {
-		location := <-clusters.LocationChannel
-		for _, locationChan := range locationChans {
-			locationChan <- location
-		}
-	}
+// A location along with what clients need to know about its user
+type locationUpdate struct {
+	models.Location
+	ImageLinkSmall string
+	HideLocation   bool
 }
 
-func sendResponse(c *websocket.Conn, location models.Location, db *gorm.DB) {
-	image := location.Image
+func sendResponse(c *websocket.Conn, update locationUpdate) error {
+	image := update.Image
 	if image == "" {
-		db.
-			Where("id = ?", location.UserID).
-			Select("image_link_small").
-			Table("users").
-			Find(&image)
+		image = update.ImageLinkSmall
 	}
 
 	response := Response{
-		Host:  location.Host,
-		Login: location.Login,
+		Host:  update.Host,
+		Login: update.Login,
 		Image: image,
-		Left:  location.EndAt != "",
+		Left:  update.EndAt != "",
+	}
+	// The seat is still shown as taken, but not by whom
+	if update.HideLocation {
+		response.Login = ""
+		response.Image = hiddenUserImage
 	}
 	bytes, err := json.Marshal(&response)
 	if err != nil {
-		return
+		return err
+	}
+	_ = c.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.WriteMessage(websocket.TextMessage, bytes)
+}
+
+// Returns the campus and the hosts of the cluster
+func getClusterSubscription(clusterID int) (int, []string) {
+	for _, cluster := range clusters.AllClusters {
+		if cluster.ID == clusterID {
+			// Without hosts, the whole campus is watched
+			hosts, _ := clusters.GetHosts(cluster)
+			return cluster.Campus.ID, hosts
+		}
 	}
-	_ = c.WriteMessage(websocket.TextMessage, bytes)
+	return -1, nil
 }
 
-// Damn, this function is huge.
 func clustersWs(db *gorm.DB) http.Handler {
-	go broadcastLocations()
+	go hub.run(db)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c, err := upgrader.Upgrade(w, r, nil)
@@ -184,76 +130,97 @@ func clustersWs(db *gorm.DB) http.Handler {
 		}
 		defer c.Close()
 
-		// Channel in which the cluster IDs are sent
-		// as they are received from the WebSocket (
-		// when e.g. the user switches to another
-		// cluster view)
-		clusterChan := make(chan int)
-		defer close(clusterChan)
+		client := newHubClient()
+		hub.register(client)
+		defer hub.unregister(client)
 
-		locationChan := make(chan models.Location)
-		locationChans = append(locationChans, locationChan)
-		defer func() {
-			locationChans = slices.DeleteFunc(locationChans,
-				func(item chan models.Location) bool {
-					return item == locationChan
-				})
-			close(locationChan)
-		}()
+		_ = c.SetReadDeadline(time.Now().Add(pongWait))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(pongWait))
+		})
 
+		// Cluster IDs are sent as they are received from
+		// the WebSocket (when e.g. the user switches to
+		// another cluster view). Only this goroutine reads,
+		// and only the handler writes.
+		clusterChan := make(chan int)
+		quit := make(chan struct{})
+		defer close(quit)
 		go func() {
+			defer close(clusterChan)
 			for {
 				_, rawMessage, err := c.ReadMessage()
 				if err != nil {
-					break
+					return
 				}
 
 				var message Message
 				err = json.Unmarshal(rawMessage, &message)
 				if err != nil {
-					break
+					return
 				}
 
-				clusterChan <- message.ClusterID
+				select {
+				case clusterChan <- message.ClusterID:
+				case <-quit:
+					return
+				}
 			}
 		}()
 
-		var stopSendingLocationsForPreviousCluster func()
-		var wantedClusterID int
+		pings := time.NewTicker(pingInterval)
+		defer pings.Stop()
 
 		for {
 			select {
 			// When the user wants to see a new cluster...
-			case wantedClusterID = <-clusterChan:
-				if wantedClusterID == 0 {
+			case clusterID, ok := <-clusterChan:
+				if !ok {
+					return
+				}
+				if clusterID == 0 {
 					break
 				}
-				if stopSendingLocationsForPreviousCluster != nil {
-					stopSendingLocationsForPreviousCluster()
+				campusID, hosts := getClusterSubscription(clusterID)
+				client.subscribe(campusID, hosts)
+
+				query := db.
+					Table("locations").
+					Select(`locations.*,
+						COALESCE(users.image_link_small, '') image_link_small,
+						COALESCE(users.hide_location, false) hide_location`).
+					Joins("LEFT JOIN users ON users.id = locations.user_id").
+					Where("locations.campus_id = ?", campusID)
+				if len(hosts) > 0 {
+					query = query.Where("locations.host IN ?", hosts)
 				}
+				var updates []locationUpdate
+				query.Scan(&updates)
 
-				var locations []models.Location
-				db.
-					Model(&models.Location{}).
-					Where("campus_id = ?", findCampusIDForCluster(wantedClusterID)).
-					Find(&locations)
-
-				if len(locations) > 0 {
-					for _, location := range locations {
-						sendResponse(c, location, db)
+				for _, update := range updates {
+					if sendResponse(c, update) != nil {
+						return
 					}
 				}
 
-			case location := <-locationChan:
-				campusID := findCampusIDForCluster(wantedClusterID)
-				if location.CampusID == campusID {
-					// Respond with user information if the location's campus
-					// is the same as the wanted cluster's campus (it would be
-					// more performant to only send locations in the specifially
-					// requested cluster, but the cable unfortunately does not
-					// tell this)
-					sendResponse(c, location, db)
+			case update := <-client.queue:
+				if sendResponse(c, update) != nil {
+					return
 				}
+
+			case <-pings.C:
+				err = c.WriteControl(websocket.PingMessage, nil,
+					time.Now().Add(writeWait))
+				if err != nil {
+					return
+				}
+
+			case <-client.overflowed:
+				_ = c.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater,
+						"too slow"),
+					time.Now().Add(writeWait))
+				return
 			}
 		}
 	})
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/clusters"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	// Samples older than this aren't current anymore
+	currentOccupancyMaxAge = time.Hour
+	forecastHours          = 6
+	// How fast the current deviation from the usual
+	// occupancy fades away in the forecast, per hour
+	forecastDecay = 0.5
+	peakCount     = 3
+)
+
+func getOccupancyHeatmap(
+	db *gorm.DB,
+	clusterIDs []int,
+	location *time.Location,
+) (templates.OccupancyHeatmap, error) {
+	var heatmap templates.OccupancyHeatmap
+	var cells []templates.OccupancyCell
+	err := db.
+		Model(&models.ClusterOccupancy{}).
+		Select(`EXTRACT(ISODOW FROM at AT TIME ZONE @tz)::int weekday,
+			EXTRACT(HOUR FROM at AT TIME ZONE @tz)::int hour,
+			COALESCE(SUM(occupied)::float / NULLIF(SUM(seats), 0), 0) ratio,
+			true has_data`, map[string]any{"tz": location.String()}).
+		Where("cluster_id IN ?", clusterIDs).
+		Where("at > ?", time.Now().Add(-clusters.OccupancyHistory)).
+		Group("weekday, hour").
+		Scan(&cells).Error
+	if err != nil {
+		return heatmap, err
+	}
+
+	for weekday := range heatmap {
+		for hour := range heatmap[weekday] {
+			heatmap[weekday][hour] = templates.OccupancyCell{
+				Weekday: weekday + 1,
+				Hour:    hour,
+			}
+		}
+	}
+	for _, cell := range cells {
+		if cell.Weekday >= 1 && cell.Weekday <= 7 &&
+			cell.Hour >= 0 && cell.Hour < 24 {
+			heatmap[cell.Weekday-1][cell.Hour] = cell
+		}
+	}
+	return heatmap, nil
+}
+
+func getClusterFills(db *gorm.DB, campusClusters []clusters.Cluster) ([]templates.ClusterFill, error) {
+	clusterIDs := make([]int, 0, len(campusClusters))
+	for _, cluster := range campusClusters {
+		clusterIDs = append(clusterIDs, cluster.ID)
+	}
+
+	var occupancies []models.ClusterOccupancy
+	err := db.
+		Model(&models.ClusterOccupancy{}).
+		Select("DISTINCT ON (cluster_id) *").
+		Where("cluster_id IN ?", clusterIDs).
+		Where("at > ?", time.Now().Add(-currentOccupancyMaxAge)).
+		Order("cluster_id, at DESC").
+		Find(&occupancies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	fills := make([]templates.ClusterFill, 0, len(occupancies))
+	for _, occupancy := range occupancies {
+		for _, cluster := range campusClusters {
+			if cluster.ID == occupancy.ClusterID {
+				fills = append(fills, templates.ClusterFill{
+					Cluster:  cluster,
+					Occupied: occupancy.Occupied,
+					Seats:    occupancy.Seats,
+				})
+			}
+		}
+	}
+	slices.SortFunc(fills, func(a, b templates.ClusterFill) int {
+		return b.Occupied*a.Seats - a.Occupied*b.Seats
+	})
+	return fills, nil
+}
+
+func getPeaks(heatmap templates.OccupancyHeatmap) []templates.OccupancyCell {
+	peaks := make([]templates.OccupancyCell, 0, 7*24)
+	for _, day := range heatmap {
+		for _, cell := range day {
+			if cell.HasData {
+				peaks = append(peaks, cell)
+			}
+		}
+	}
+	slices.SortFunc(peaks, func(a, b templates.OccupancyCell) int {
+		return cmp.Compare(b.Ratio, a.Ratio)
+	})
+	return peaks[:min(peakCount, len(peaks))]
+}
+
+func occupancyAt(heatmap templates.OccupancyHeatmap, at time.Time) templates.OccupancyCell {
+	weekday := (int(at.Weekday())+6)%7 + 1
+	return heatmap[weekday-1][at.Hour()]
+}
+
+// Expects the next hours to be as busy as usual, plus the
+// difference between the current and usual occupancy,
+// which fades away as time goes by
+func forecastOccupancy(
+	heatmap templates.OccupancyHeatmap,
+	fills []templates.ClusterFill,
+	now time.Time,
+) []templates.OccupancyForecast {
+	var occupied, seats int
+	for _, fill := range fills {
+		occupied += fill.Occupied
+		seats += fill.Seats
+	}
+	usual := occupancyAt(heatmap, now)
+	deviation := 0.0
+	if seats != 0 && usual.HasData {
+		deviation = float64(occupied)/float64(seats) - usual.Ratio
+	}
+
+	forecasts := make([]templates.OccupancyForecast, 0, forecastHours)
+	for i := 1; i <= forecastHours; i++ {
+		at := now.Truncate(time.Hour).Add(time.Duration(i) * time.Hour)
+		expected := occupancyAt(heatmap, at)
+		if !expected.HasData {
+			continue
+		}
+		ratio := expected.Ratio + deviation*math.Pow(forecastDecay, float64(i))
+		forecasts = append(forecasts, templates.OccupancyForecast{
+			At:    at,
+			Ratio: math.Max(0, math.Min(1, ratio)),
+		})
+	}
+	return forecasts
+}
+
+func handleClusterStats(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		campusID := getCampusIDFromQuery(r)
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get campuses: %w", err))
+			return
+		}
+		var campus models.Campus
+		for _, possibleCampus := range campuses {
+			if possibleCampus.ID == campusID {
+				campus = possibleCampus
+			}
+		}
+		location := campus.Location()
+
+		campusClusters := make([]clusters.Cluster, 0)
+		clusterIDs := make([]int, 0)
+		for _, cluster := range clusters.AllClusters {
+			if cluster.Campus.ID == campusID {
+				campusClusters = append(campusClusters, cluster)
+				clusterIDs = append(clusterIDs, cluster.ID)
+			}
+		}
+
+		heatmap, err := getOccupancyHeatmap(db, clusterIDs, location)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get occupancy: %w", err))
+			return
+		}
+		fills, err := getClusterFills(db, campusClusters)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get current occupancy: %w", err))
+			return
+		}
+
+		_ = templates.ClusterStats(campuses, campusID,
+			fills, heatmap, getPeaks(heatmap),
+			forecastOccupancy(heatmap, fills, time.Now().In(location)),
+		).Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,362 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSuggestedPeers = 10
+	// How far from the user's level suggested peers can be
+	similarLevelRange = 0.5
+)
+
+func getCollaborators(db *gorm.DB, userID int) ([]templates.Collaborator, error) {
+	var rows []struct {
+		UserID      int
+		TeamID      int
+		SubjectName string
+		FinalMark   *int
+		Validated   *bool
+	}
+	err := db.
+		Table("team_users mine").
+		Select(`theirs.user_id, teams.id team_id, subjects.name subject_name,
+			teams.final_mark, teams.validated`).
+		Joins(`JOIN team_users theirs ON theirs.team_id = mine.team_id
+			AND theirs.user_id != mine.user_id`).
+		Joins("JOIN teams ON teams.id = mine.team_id").
+		// Every project the team is linked to is on the same subject
+		Joins(`JOIN subjects ON subjects.id = (SELECT projects.subject_id
+			FROM project_teams
+			JOIN projects ON projects.id = project_teams.project_id
+			WHERE project_teams.team_id = teams.id
+			LIMIT 1)`).
+		Where("mine.user_id = ?", userID).
+		Order("teams.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	projectsByUser := make(map[int][]templates.CollaborationProject)
+	userIDs := make([]int, 0)
+	for _, row := range rows {
+		if _, ok := projectsByUser[row.UserID]; !ok {
+			userIDs = append(userIDs, row.UserID)
+		}
+		projectsByUser[row.UserID] = append(projectsByUser[row.UserID],
+			templates.CollaborationProject{
+				TeamID:      row.TeamID,
+				SubjectName: row.SubjectName,
+				FinalMark:   row.FinalMark,
+				Validated:   row.Validated,
+			})
+	}
+
+	var users []models.User
+	err = db.
+		Where("id IN ?", userIDs).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	collaborators := make([]templates.Collaborator, 0, len(users))
+	for _, user := range users {
+		collaborators = append(collaborators, templates.Collaborator{
+			User:     user,
+			Projects: projectsByUser[user.ID],
+		})
+	}
+	slices.SortFunc(collaborators, func(a, b templates.Collaborator) int {
+		if len(a.Projects) != len(b.Projects) {
+			return len(b.Projects) - len(a.Projects)
+		}
+		if a.Login < b.Login {
+			return -1
+		}
+		return 1
+	})
+	return collaborators, nil
+}
+
+// Campus peers around the same level the
+// user never was in a team with
+func getSuggestedPeers(
+	db *gorm.DB,
+	user models.User,
+	collaborators []templates.Collaborator,
+) ([]models.User, error) {
+	excluded := []int{user.ID}
+	for _, collaborator := range collaborators {
+		excluded = append(excluded, collaborator.ID)
+	}
+
+	var peers []models.User
+	err := db.
+		Scopes(database.OnlyMainCursusUsers()).
+		Where("campus_id = ?", user.CampusID).
+		Where("id NOT IN ?", excluded).
+		Where("level BETWEEN ? AND ?",
+			user.Level-similarLevelRange, user.Level+similarLevelRange).
+		Order(fmt.Sprintf("ABS(level - %f)", user.Level)).
+		Limit(maxSuggestedPeers).
+		Find(&peers).Error
+	return peers, err
+}
+
+func getUserFromQuery(db *gorm.DB, r *http.Request) (models.User, error) {
+	login := r.URL.Query().Get("user")
+	if login == "" {
+		login = getLoggedInUser(r).them.Login
+	}
+	var user models.User
+	err := db.
+		Where("login = ?", login).
+		Where("is_test = false").
+		First(&user).Error
+	return user, err
+}
+
+func handleCollaborations(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := getUserFromQuery(db, r)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+
+		collaborators, err := getCollaborators(db, user.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get collaborators: %w", err))
+			return
+		}
+		suggestions, err := getSuggestedPeers(db, user, collaborators)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get suggestions: %w", err))
+			return
+		}
+
+		_ = templates.Collaborations(user, collaborators, suggestions,
+			isStaff(getLoggedInUser(r)),
+		).Render(r.Context(), w)
+	})
+}
+
+type collaborationsResponse struct {
+	Login         string                 `json:"login"`
+	Collaborators []collaboratorResponse `json:"collaborators"`
+	Suggestions   []string               `json:"suggestions"`
+}
+
+type collaboratorResponse struct {
+	Login    string                           `json:"login"`
+	Teams    int                              `json:"teams"`
+	Projects []templates.CollaborationProject `json:"projects"`
+}
+
+func collaborationsAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := getUserFromQuery(db, r)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+
+		collaborators, err := getCollaborators(db, user.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get collaborators: %w", err))
+			return
+		}
+		suggestions, err := getSuggestedPeers(db, user, collaborators)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get suggestions: %w", err))
+			return
+		}
+
+		response := collaborationsResponse{
+			Login:         user.Login,
+			Collaborators: make([]collaboratorResponse, 0, len(collaborators)),
+			Suggestions:   make([]string, 0, len(suggestions)),
+		}
+		for _, collaborator := range collaborators {
+			response.Collaborators = append(response.Collaborators,
+				collaboratorResponse{
+					Login:    collaborator.Login,
+					Teams:    len(collaborator.Projects),
+					Projects: collaborator.Projects,
+				})
+		}
+		for _, suggestion := range suggestions {
+			response.Suggestions = append(response.Suggestions, suggestion.Login)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+type collaborationEdge struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+	// How many teams they were in together
+	Weight int `json:"weight"`
+}
+
+type collaborationNode struct {
+	ID    int     `json:"id"`
+	Login string  `json:"login"`
+	Level float64 `json:"level"`
+}
+
+type collaborationGraph struct {
+	Nodes []collaborationNode `json:"nodes"`
+	Edges []collaborationEdge `json:"edges"`
+}
+
+func getCampusCollaborationGraph(db *gorm.DB, campusID int) (*collaborationGraph, error) {
+	graph := collaborationGraph{
+		Nodes: make([]collaborationNode, 0),
+		Edges: make([]collaborationEdge, 0),
+	}
+
+	err := db.
+		Model(&models.User{}).
+		Select("id, login, level").
+		Scopes(database.OnlyMainCursusUsers()).
+		Where("campus_id = ?", campusID).
+		Order("id").
+		Scan(&graph.Nodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	campusUsers := db.
+		Model(&models.User{}).
+		Select("id").
+		Scopes(database.OnlyMainCursusUsers()).
+		Where("campus_id = ?", campusID)
+	err = db.
+		Table("team_users a").
+		Select("a.user_id source, b.user_id target, COUNT(DISTINCT a.team_id) weight").
+		Joins("JOIN team_users b ON b.team_id = a.team_id AND a.user_id < b.user_id").
+		Where("a.user_id IN (?)", campusUsers).
+		Where("b.user_id IN (?)", campusUsers).
+		Group("a.user_id, b.user_id").
+		Scan(&graph.Edges).Error
+	if err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func (graph *collaborationGraph) toGraphML() graphML {
+	var result graphML
+	result.Xmlns = "http://graphml.graphdrawing.org/xmlns"
+	result.Keys = []graphMLKey{
+		{ID: "login", For: "node", AttrName: "login", AttrType: "string"},
+		{ID: "level", For: "node", AttrName: "level", AttrType: "double"},
+		{ID: "weight", For: "edge", AttrName: "weight", AttrType: "int"},
+	}
+	result.Graph.EdgeDefault = "undirected"
+	for _, node := range graph.Nodes {
+		result.Graph.Nodes = append(result.Graph.Nodes, graphMLNode{
+			ID: strconv.Itoa(node.ID),
+			Data: []graphMLData{
+				{Key: "login", Value: node.Login},
+				{Key: "level", Value: strconv.FormatFloat(node.Level, 'f', 2, 64)},
+			},
+		})
+	}
+	for _, edge := range graph.Edges {
+		result.Graph.Edges = append(result.Graph.Edges, graphMLEdge{
+			Source: strconv.Itoa(edge.Source),
+			Target: strconv.Itoa(edge.Target),
+			Data: []graphMLData{
+				{Key: "weight", Value: strconv.Itoa(edge.Weight)},
+			},
+		})
+	}
+	return result
+}
+
+func collaborationsExport(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		campusID, err := strconv.Atoi(r.URL.Query().Get("campus"))
+		if err != nil {
+			campusID = getLoggedInUser(r).them.CampusID
+		}
+
+		graph, err := getCampusCollaborationGraph(db, campusID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get collaboration graph: %w", err))
+			return
+		}
+
+		if r.URL.Path == "/collaborations.graphml" {
+			w.Header().Set("Content-Type", "application/graphml+xml")
+			w.Header().Set("Content-Disposition",
+				fmt.Sprintf("attachment; filename=collaborations-%d.graphml", campusID))
+			_, _ = w.Write([]byte(xml.Header))
+			encoder := xml.NewEncoder(w)
+			encoder.Indent("", "\t")
+			_ = encoder.Encode(graph.toGraphML())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(graph)
+	})
+}
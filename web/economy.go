@@ -0,0 +1,101 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	usersPerEconomyList = 10
+	// Users with this many correction points or
+	// less can't be evaluated more than once
+	runningOutOfPoints = 1
+)
+
+func getPointsHistory(db *gorm.DB, userID int) ([]models.PointsHistory, error) {
+	var history []models.PointsHistory
+	err := db.
+		Model(&models.PointsHistory{}).
+		Where("user_id = ?", userID).
+		Order("at").
+		Find(&history).Error
+	return history, err
+}
+
+func handleEconomy(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me := getLoggedInUser(r).them
+
+		campusID, err := strconv.Atoi(r.URL.Query().Get("campus"))
+		if err != nil {
+			campusID = me.CampusID
+		}
+		inCampus := func(db *gorm.DB) *gorm.DB {
+			return db.
+				Scopes(database.OnlyMainCursusUsers()).
+				Where("campus_id = ?", campusID)
+		}
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not fetch campuses: %w", err))
+			return
+		}
+
+		var stats templates.EconomyStats
+		err = db.
+			Model(&models.User{}).
+			Select(`COUNT(*) users,
+				COALESCE(SUM(correction_points), 0) correction_points,
+				COALESCE(SUM(wallets), 0) wallets,
+				COUNT(*) FILTER (WHERE correction_points <= ?) running_out`,
+				runningOutOfPoints).
+			Scopes(inCampus).
+			Scan(&stats).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get campus economy: %w", err))
+			return
+		}
+
+		var hoarders []models.User
+		err = db.
+			Model(&models.User{}).
+			Scopes(inCampus).
+			Order("correction_points DESC").
+			Limit(usersPerEconomyList).
+			Find(&hoarders).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get hoarders: %w", err))
+			return
+		}
+
+		var runningOut []models.User
+		err = db.
+			Model(&models.User{}).
+			Scopes(inCampus).
+			Where("correction_points <= ?", runningOutOfPoints).
+			Order("correction_points, login").
+			Limit(usersPerEconomyList).
+			Find(&runningOut).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get users running out of points: %w", err))
+			return
+		}
+
+		history, err := getPointsHistory(db, me.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get points history: %w", err))
+			return
+		}
+
+		_ = templates.Economy(stats, hoarders, runningOut, history,
+			campuses, campusID, me.ID,
+		).Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const evaluationAggregates = `COUNT(*) evaluations,
+	AVG(evaluations.final_mark) average_mark,
+	AVG(evaluations.feedback_rating) average_rating,
+	ROUND(AVG(evaluations.duration))::bigint average_duration`
+
+func evaluationsInCampus(campusID int, subjectID int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.
+			Joins("JOIN users ON users.id = evaluations.evaluator_id").
+			Where("users.campus_id = ?", campusID).
+			Where(database.OnlyRealUsersCondition)
+		if subjectID != 0 {
+			db = db.Where("evaluations.subject_id = ?", subjectID)
+		}
+		return db
+	}
+}
+
+func handleEvaluators(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page <= 0 {
+			page = 1
+		}
+
+		campusID, err := strconv.Atoi(r.URL.Query().Get("campus"))
+		if err != nil {
+			campusID = getLoggedInUser(r).them.CampusID
+		}
+		subjectID, _ := strconv.Atoi(r.URL.Query().Get("subject"))
+		evaluatorLogin := r.URL.Query().Get("evaluator")
+
+		sorting := r.URL.Query().Get("sort")
+		if sorting == "" || !canSortOn(templates.EvaluatorFields, sorting) {
+			sorting = "evaluations"
+		}
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not fetch campuses: %w", err))
+			return
+		}
+
+		var subjects []models.Subject
+		err = db.
+			Model(&models.Subject{}).
+			Where("id IN (?)", db.
+				Model(&models.Evaluation{}).
+				Select("evaluations.subject_id").
+				Scopes(evaluationsInCampus(campusID, 0))).
+			Order("name").
+			Find(&subjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not list subjects: %w", err))
+			return
+		}
+
+		// Drilling down into a single evaluator shows
+		// their statistics for each project instead
+		if evaluatorLogin != "" {
+			var projectStats []templates.EvaluatorProjectStats
+			err = db.
+				Model(&models.Evaluation{}).
+				Select("subjects.name subject_name, "+evaluationAggregates).
+				Joins("JOIN subjects ON subjects.id = evaluations.subject_id").
+				Scopes(evaluationsInCampus(campusID, subjectID)).
+				Where("users.login = ?", evaluatorLogin).
+				Group("subjects.name").
+				Order(sorting + " DESC NULLS LAST").
+				Scan(&projectStats).Error
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to get evaluator stats: %w", err))
+				return
+			}
+
+			_ = templates.EvaluatorProjects(evaluatorLogin,
+				projectStats, r.URL, campusID,
+			).Render(r.Context(), w)
+			return
+		}
+
+		var totalEvaluators int64
+		err = db.
+			Model(&models.Evaluation{}).
+			Scopes(evaluationsInCampus(campusID, subjectID)).
+			Distinct("evaluations.evaluator_id").
+			Count(&totalEvaluators).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not get evaluator count: %w", err))
+			return
+		}
+		totalPages := max(1, 1+(int(totalEvaluators)-1)/UsersPerPage)
+		page = min(page, totalPages)
+		offset := (page - 1) * UsersPerPage
+
+		var evaluators []templates.EvaluatorStats
+		err = db.
+			Model(&models.Evaluation{}).
+			Select("users.*, " + evaluationAggregates).
+			Scopes(evaluationsInCampus(campusID, subjectID)).
+			Group("users.id").
+			Order(sorting + " DESC NULLS LAST").
+			Offset(offset).
+			Limit(UsersPerPage).
+			Scan(&evaluators).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to list evaluators: %w", err))
+			return
+		}
+
+		_ = templates.Evaluators(evaluators,
+			campuses, campusID, subjects, subjectID,
+			r.URL, page, totalPages, offset,
+			getLoggedInUser(r).them.ID,
+		).Render(r.Context(), w)
+	})
+}
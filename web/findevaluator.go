@@ -0,0 +1,122 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/internal/slots"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+// Defaults to a project the user is waiting corrections for
+func getWaitingSubjectID(db *gorm.DB, userID int) int {
+	var subjectID int
+	db.
+		Model(&models.Project{}).
+		Select("projects.subject_id").
+		Where(projectsOf(db, userID)).
+		Where("projects.status = 'waiting_for_correction'").
+		Limit(1).
+		Find(&subjectID)
+	return subjectID
+}
+
+// Slots are fetched with one request per user,
+// so only the best candidates are kept fresh
+const candidatesWithFreshSlots = 10
+
+func setNextSlots(db *gorm.DB, candidates []templates.EvaluatorCandidate) error {
+	userIDs := make([]int, 0, len(candidates))
+	for _, candidate := range candidates {
+		userIDs = append(userIDs, candidate.ID)
+	}
+	err := slots.Want(db,
+		userIDs[:min(len(userIDs), candidatesWithFreshSlots)])
+	if err != nil {
+		return err
+	}
+
+	var nextSlots []struct {
+		UserID   int
+		NextSlot time.Time
+	}
+	err = db.
+		Model(&models.Slot{}).
+		Select("user_id, MIN(begin_at) next_slot").
+		Where("user_id IN ?", userIDs).
+		Where("end_at > NOW()").
+		Group("user_id").
+		Scan(&nextSlots).Error
+	if err != nil {
+		return err
+	}
+	for i := range nextSlots {
+		for j := range candidates {
+			if candidates[j].ID == nextSlots[i].UserID {
+				candidates[j].NextSlot = &nextSlots[i].NextSlot
+			}
+		}
+	}
+	return nil
+}
+
+func handleFindEvaluator(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me := getLoggedInUser(r).them
+
+		var subjects []models.Subject
+		err := db.
+			Model(&models.Subject{}).
+			Order("position, name").
+			Where(database.UnwantedSubjectsCondition).
+			Find(&subjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get subjects: %w", err))
+			return
+		}
+
+		subjectID, err := strconv.Atoi(r.URL.Query().Get("subject"))
+		if err != nil {
+			subjectID = getWaitingSubjectID(db, me.ID)
+		}
+
+		var candidates []templates.EvaluatorCandidate
+		if subjectID != 0 {
+			err = db.
+				Model(&models.User{}).
+				Select(`users.*,
+					(SELECT COUNT(*) FROM evaluations
+						WHERE evaluations.evaluator_id = users.id
+						AND evaluations.subject_id = ?) evaluations,
+					COALESCE((SELECT host FROM locations
+						WHERE locations.user_id = users.id
+						AND NOT users.hide_location
+						LIMIT 1), '') host`,
+					subjectID).
+				Scopes(database.OnlyRealUsers()).
+				Where("campus_id = ?", me.CampusID).
+				Where("id != ?", me.ID).
+				Where("id IN (?)", database.UsersWhoValidated(db, subjectID)).
+				Order("evaluations DESC, host DESC").
+				Limit(UsersPerPage).
+				Find(&candidates).Error
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to find evaluators: %w", err))
+				return
+			}
+			err = setNextSlots(db, candidates)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to get slots: %w", err))
+				return
+			}
+		}
+
+		_ = templates.FindEvaluator(subjects, subjectID, candidates).
+			Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/demostanis/42evaluators/internal/clusters"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+func getCampusIDFromQuery(r *http.Request) int {
+	campusID, err := strconv.Atoi(r.URL.Query().Get("campus"))
+	if err != nil {
+		return getLoggedInUser(r).them.CampusID
+	}
+	return campusID
+}
+
+// Returns the hosts nobody is logged in on, for each
+// cluster of the campus, emptiest clusters first
+func getFreeSeats(db *gorm.DB, campusID int) ([]templates.FreeCluster, error) {
+	var takenHosts []string
+	err := db.
+		Model(&models.Location{}).
+		Where("campus_id = ?", campusID).
+		Pluck("host", &takenHosts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	freeClusters := make([]templates.FreeCluster, 0)
+	for _, cluster := range clusters.AllClusters {
+		if cluster.Campus.ID != campusID {
+			continue
+		}
+		hosts, err := clusters.GetHosts(cluster)
+		// The map probably moved
+		if err != nil || len(hosts) == 0 {
+			continue
+		}
+
+		freeCluster := templates.FreeCluster{
+			ID:        cluster.ID,
+			Name:      cluster.Name,
+			Seats:     len(hosts),
+			FreeHosts: make([]string, 0),
+		}
+		for _, host := range hosts {
+			if !slices.Contains(takenHosts, host) {
+				freeCluster.FreeHosts = append(freeCluster.FreeHosts, host)
+			}
+		}
+		slices.Sort(freeCluster.FreeHosts)
+		freeCluster.Free = len(freeCluster.FreeHosts)
+		freeClusters = append(freeClusters, freeCluster)
+	}
+
+	slices.SortFunc(freeClusters, func(a, b templates.FreeCluster) int {
+		return b.Free - a.Free
+	})
+	return freeClusters, nil
+}
+
+type freeSeatsResponse struct {
+	CampusID int                     `json:"campus_id"`
+	Free     int                     `json:"free"`
+	Seats    int                     `json:"seats"`
+	Clusters []templates.FreeCluster `json:"clusters"`
+}
+
+func freeSeatsAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		campusID := getCampusIDFromQuery(r)
+		freeClusters, err := getFreeSeats(db, campusID)
+		if err != nil {
+			internalServerError(w, err)
+			return
+		}
+
+		response := freeSeatsResponse{
+			CampusID: campusID,
+			Clusters: freeClusters,
+		}
+		for _, cluster := range freeClusters {
+			response.Free += cluster.Free
+			response.Seats += cluster.Seats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+func handleFreeSeats(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		campusID := getCampusIDFromQuery(r)
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get campuses: %w", err))
+			return
+		}
+		freeClusters, err := getFreeSeats(db, campusID)
+		if err != nil {
+			internalServerError(w, err)
+			return
+		}
+
+		_ = templates.FreeSeats(campuses, campusID, freeClusters).
+			Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/internal/projects"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+// Returns how many users of the campus are doing each subject
+func getPeersBySubject(db *gorm.DB, campusID int, myID int) (map[int]int, error) {
+	var counts []struct {
+		SubjectID int
+		Peers     int
+	}
+	err := db.
+		Model(&models.Project{}).
+		Select("projects.subject_id, COUNT(DISTINCT users.id) peers").
+		Joins("JOIN users ON "+database.ProjectUserCondition).
+		Where("projects.status != 'finished'").
+		Where("users.campus_id = ?", campusID).
+		Where("users.id != ?", myID).
+		Where(database.OnlyRealUsersCondition).
+		Group("projects.subject_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[int]int)
+	for _, count := range counts {
+		peers[count.SubjectID] = count.Peers
+	}
+	return peers, nil
+}
+
+func handleGraph(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me := getLoggedInUser(r).them
+
+		var myProjects []models.Project
+		err := db.
+			Where(projectsOf(db, me.ID)).
+			Find(&myProjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get projects: %w", err))
+			return
+		}
+		projectsBySubject := make(map[int]models.Project)
+		for _, project := range myProjects {
+			projectsBySubject[project.SubjectID] = project
+		}
+
+		peers, err := getPeersBySubject(db, me.CampusID, me.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to count peers: %w", err))
+			return
+		}
+
+		projectData := projects.GetProjectData()
+		nodes := make([]templates.GraphNode, 0, len(projectData))
+		for _, data := range projectData {
+			status := templates.NotStarted
+			if project, ok := projectsBySubject[data.ProjectID]; ok {
+				switch {
+				case project.Validated ||
+					(project.Status == "finished" && project.FinalMark >= 50):
+					status = templates.Validated
+				case project.Status == "finished":
+					status = templates.Failed
+				default:
+					status = templates.InProgress
+				}
+			}
+
+			nodes = append(nodes, templates.GraphNode{
+				ProjectData: data,
+				Status:      status,
+				Peers:       peers[data.ProjectID],
+			})
+		}
+
+		_ = templates.Graph(nodes, me.CampusID).
+			Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+// The snapshot column holding the rank which matches the
+// leaderboard's filters, or "" if none does
+func getRankColumn(sorting string, campus string, promo string) string {
+	if sorting != "level" {
+		return ""
+	}
+	switch {
+	case campus != "" && promo != "":
+		return "promo_rank"
+	case campus != "":
+		return "campus_rank"
+	case promo == "":
+		return "global_rank"
+	}
+	// Promos across every campus aren't snapshotted
+	return ""
+}
+
+func getRankChanges(
+	db *gorm.DB,
+	cursusID int,
+	rankColumn string,
+	users []models.User,
+) (map[int]templates.RankChange, error) {
+	changes := make(map[int]templates.RankChange)
+	if rankColumn == "" || len(users) == 0 {
+		return changes, nil
+	}
+
+	userIDs := make([]int, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+
+	var ranks []struct {
+		UserID  int
+		DaysAgo int
+		Rank    int
+	}
+	err := db.
+		Model(&models.LevelSnapshot{}).
+		Select("user_id, CURRENT_DATE - date days_ago, "+rankColumn+" AS rank").
+		Where("cursus_id = ?", cursusID).
+		Where("user_id IN ?", userIDs).
+		Where("date IN (CURRENT_DATE, CURRENT_DATE - 1, CURRENT_DATE - 7)").
+		Scan(&ranks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ranksByUser := make(map[int]map[int]int)
+	for _, rank := range ranks {
+		if ranksByUser[rank.UserID] == nil {
+			ranksByUser[rank.UserID] = make(map[int]int)
+		}
+		ranksByUser[rank.UserID][rank.DaysAgo] = rank.Rank
+	}
+	for userID, userRanks := range ranksByUser {
+		today, ok := userRanks[0]
+		if !ok {
+			continue
+		}
+		var change templates.RankChange
+		if yesterday, ok := userRanks[1]; ok {
+			diff := yesterday - today
+			change.SinceYesterday = &diff
+		}
+		if lastWeek, ok := userRanks[7]; ok {
+			diff := lastWeek - today
+			change.SinceLastWeek = &diff
+		}
+		changes[userID] = change
+	}
+	return changes, nil
+}
+
+func handleLevelHistory(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursusID := getCursusID(r)
+		login := r.URL.Query().Get("user")
+		if login == "" {
+			login = getLoggedInUser(r).them.Login
+		}
+
+		var user models.User
+		err := db.
+			Scopes(database.OnlyRealUsers()).
+			Where("login = ?", login).
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+
+		cursuses, err := getAllCursuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not fetch cursuses: %w", err))
+			return
+		}
+
+		var snapshots []models.LevelSnapshot
+		err = db.
+			Model(&models.LevelSnapshot{}).
+			Where("user_id = ?", user.ID).
+			Where("cursus_id = ?", cursusID).
+			Order("date").
+			Find(&snapshots).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get level history: %w", err))
+			return
+		}
+
+		_ = templates.LevelHistory(user, snapshots,
+			cursuses, cursusID,
+		).Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"sync"
+
+	"github.com/demostanis/42evaluators/internal/clusters"
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+// How many locations can wait to be sent to a client
+// before it's considered too slow and disconnected
+const clientQueueSize = 64
+
+// A client of the clusters WebSocket, which only
+// receives the locations of the cluster it watches
+type hubClient struct {
+	queue chan locationUpdate
+	// Closed when the client couldn't keep up
+	overflowed   chan struct{}
+	overflowOnce sync.Once
+
+	mu       sync.RWMutex
+	campusID int
+	// If empty, every location of the campus is sent,
+	// since the cluster's map couldn't be parsed
+	hosts map[string]bool
+}
+
+func newHubClient() *hubClient {
+	return &hubClient{
+		queue:      make(chan locationUpdate, clientQueueSize),
+		overflowed: make(chan struct{}),
+		campusID:   -1,
+	}
+}
+
+// Watches another cluster. Locations queued for the previous
+// one are dropped, since they'd show up on the wrong map.
+func (client *hubClient) subscribe(campusID int, hosts []string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.campusID = campusID
+	client.hosts = make(map[string]bool)
+	for _, host := range hosts {
+		client.hosts[host] = true
+	}
+	for {
+		select {
+		case <-client.queue:
+		default:
+			return
+		}
+	}
+}
+
+// The caller must hold client.mu
+func (client *hubClient) wants(location models.Location) bool {
+	if location.CampusID != client.campusID {
+		return false
+	}
+	return len(client.hosts) == 0 || client.hosts[location.Host]
+}
+
+// Queues the location if the client watches its cluster. Never
+// blocks, slow clients get disconnected instead of holding back
+// everyone else.
+func (client *hubClient) send(update locationUpdate) {
+	// Held while queueing, so that subscribe
+	// can't miss locations of the old cluster
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	if !client.wants(update.Location) {
+		return
+	}
+	select {
+	case client.queue <- update:
+	default:
+		client.overflowOnce.Do(func() {
+			close(client.overflowed)
+		})
+	}
+}
+
+type locationHub struct {
+	mu      sync.RWMutex
+	clients map[*hubClient]bool
+}
+
+var hub = locationHub{
+	clients: make(map[*hubClient]bool),
+}
+
+func (hub *locationHub) register(client *hubClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.clients[client] = true
+}
+
+func (hub *locationHub) unregister(client *hubClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.clients, client)
+}
+
+func (hub *locationHub) broadcast(update locationUpdate) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	for client := range hub.clients {
+		client.send(update)
+	}
+}
+
+// The user of each location is only looked up
+// once, whoever it's broadcasted to
+func (hub *locationHub) run(db *gorm.DB) {
+	for {
+		location := <-clusters.LocationChannel
+		var user struct {
+			ImageLinkSmall string
+			HideLocation   bool
+		}
+		db.
+			Where("id = ?", location.UserID).
+			Select("image_link_small, hide_location").
+			Table("users").
+			Find(&user)
+		hub.broadcast(locationUpdate{
+			Location:       location,
+			ImageLinkSmall: user.ImageLinkSmall,
+			HideLocation:   user.HideLocation,
+		})
+	}
+}
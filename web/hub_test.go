@@ -0,0 +1,90 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+)
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHubDisconnectsSlowClients(t *testing.T) {
+	hub := locationHub{clients: make(map[*hubClient]bool)}
+
+	slow := newHubClient()
+	slow.subscribe(1, nil)
+	hub.register(slow)
+	fast := newHubClient()
+	fast.subscribe(1, nil)
+	hub.register(fast)
+
+	// The fast client reads each location before the next one
+	// is broadcasted, while the slow one never reads any
+	const count = clientQueueSize * 2
+	received := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < count; i++ {
+			hub.broadcast(locationUpdate{
+				Location: models.Location{ID: i, CampusID: 1, Host: "e1r1p1"},
+			})
+			select {
+			case <-fast.queue:
+				received++
+			default:
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a slow client blocked the broadcast")
+	}
+	if received != count {
+		t.Errorf("fast client got %d locations, want %d", received, count)
+	}
+	if !isClosed(slow.overflowed) {
+		t.Error("slow client wasn't disconnected")
+	}
+	if isClosed(fast.overflowed) {
+		t.Error("fast client was disconnected")
+	}
+}
+
+func TestHubClientFiltersLocations(t *testing.T) {
+	client := newHubClient()
+	client.subscribe(1, []string{"e1r1p1", "e1r1p2"})
+
+	client.send(locationUpdate{Location: models.Location{CampusID: 1, Host: "e1r1p1"}})
+	client.send(locationUpdate{Location: models.Location{CampusID: 1, Host: "e2r1p1"}})
+	client.send(locationUpdate{Location: models.Location{CampusID: 2, Host: "e1r1p2"}})
+
+	if len(client.queue) != 1 {
+		t.Fatalf("got %d queued locations, want 1", len(client.queue))
+	}
+	if update := <-client.queue; update.Host != "e1r1p1" {
+		t.Errorf("got %s queued, want e1r1p1", update.Host)
+	}
+}
+
+func TestHubClientSubscribeDropsQueue(t *testing.T) {
+	client := newHubClient()
+	client.subscribe(1, nil)
+	client.send(locationUpdate{Location: models.Location{CampusID: 1, Host: "e1r1p1"}})
+
+	client.subscribe(2, nil)
+	if len(client.queue) != 0 {
+		t.Errorf("%d locations of the previous cluster are still queued",
+			len(client.queue))
+	}
+}
@@ -55,7 +55,7 @@ func handleIndex(db *gorm.DB) http.Handler {
 				AuthenticatedAs(accessToken))
 
 			if err == nil {
-				w.Header().Add("Set-Cookie", "token="+accessToken+"; HttpOnly")
+				w.Header().Add("Set-Cookie", "token="+accessToken+"; HttpOnly; SameSite=Lax")
 				mu.Lock()
 				loggedInUsers = append(loggedInUsers, LoggedInUser{
 					accessToken,
@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/demostanis/42evaluators/internal/database"
 	"github.com/demostanis/42evaluators/internal/models"
@@ -15,16 +16,21 @@ import (
 
 // Processes the user's ?fields= URL param by splitting it
 // on commas and returning a map of valid (according to
-// templates.ToggleableFields) templates.Fields.
-func getShownFields(wantedFieldsRaw string) map[string]templates.Field {
+// toggleableFields) templates.Fields. defaultFields are
+// the ones shown when the param is empty.
+func getShownFields(
+	toggleableFields []templates.Field,
+	defaultFields []string,
+	wantedFieldsRaw string,
+) map[string]templates.Field {
 	shownFields := make(map[string]templates.Field)
 
-	wantedFields := []string{"level", "campus"}
+	wantedFields := defaultFields
 	if wantedFieldsRaw != "" {
 		wantedFields = strings.Split(wantedFieldsRaw, ",")
 	}
 
-	for _, field := range templates.ToggleableFields {
+	for _, field := range toggleableFields {
 		found := false
 		for _, allowedField := range wantedFields {
 			if field.Name == allowedField {
@@ -42,8 +48,8 @@ func getShownFields(wantedFieldsRaw string) map[string]templates.Field {
 	return shownFields
 }
 
-func canSortOn(field string) bool {
-	for _, toggleableField := range templates.ToggleableFields {
+func canSortOn(toggleableFields []templates.Field, field string) bool {
+	for _, toggleableField := range toggleableFields {
 		if toggleableField.Name == field && toggleableField.Sortable {
 			return true
 		}
@@ -53,6 +59,7 @@ func canSortOn(field string) bool {
 
 func getPromosForCampus(
 	db *gorm.DB,
+	cursusID int,
 	campus string,
 	promo string,
 ) ([]templates.Promo, error) {
@@ -60,6 +67,7 @@ func getPromosForCampus(
 
 	var campusUsers []models.User
 	err := db.
+		Scopes(database.WithCursus(cursusID)).
 		Scopes(database.WithCampus(campus)).
 		Scopes(database.OnlyRealUsers()).
 		Find(&campusUsers).Error
@@ -109,6 +117,40 @@ func getAllCampuses(db *gorm.DB) ([]models.Campus, error) {
 	return campuses, err
 }
 
+func getAllCursuses(db *gorm.DB) ([]models.Cursus, error) {
+	var cursuses []models.Cursus
+	err := db.Order("id DESC").Find(&cursuses).Error
+	return cursuses, err
+}
+
+// Total logtimes only add up the days in logtime_days,
+// which might not go back to the start of the cursus
+func labelTotalLogtime(db *gorm.DB, shownFields map[string]templates.Field) error {
+	var firstDay *time.Time
+	err := db.
+		Model(&models.LogtimeDay{}).
+		Select("MIN(date)").
+		Scan(&firstDay).Error
+	if err != nil || firstDay == nil {
+		return err
+	}
+
+	field := shownFields["total_logtime"]
+	field.PrettyName = fmt.Sprintf("Logtime since %s",
+		firstDay.Format("Jan 2, 2006"))
+	shownFields["total_logtime"] = field
+	return nil
+}
+
+// Returns the ?cursus= URL param, or the main cursus
+func getCursusID(r *http.Request) int {
+	cursusID, err := strconv.Atoi(r.URL.Query().Get("cursus"))
+	if err != nil {
+		return models.MainCursusID
+	}
+	return cursusID
+}
+
 func internalServerError(w http.ResponseWriter, err error) {
 	// TODO: stream this to errstream
 	w.WriteHeader(http.StatusInternalServerError)
@@ -123,23 +165,37 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 		}
 
 		sorting := r.URL.Query().Get("sort")
-		if sorting == "" || !canSortOn(sorting) {
+		if sorting == "" || !canSortOn(templates.ToggleableFields, sorting) {
 			sorting = "level"
 		}
 
+		cursusID := getCursusID(r)
 		promo := r.URL.Query().Get("promo")
 		campus := r.URL.Query().Get("campus")
 		showMyself := r.URL.Query().Get("me") != ""
-		shownFields := getShownFields(r.URL.Query().Get("fields"))
+		shownFields := getShownFields(templates.ToggleableFields,
+			[]string{"level", "campus"}, r.URL.Query().Get("fields"))
 		search := r.URL.Query().Get("search")
 
+		err = labelTotalLogtime(db, shownFields)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not find the first logtime day: %w", err))
+			return
+		}
+
 		campuses, err := getAllCampuses(db)
 		if err != nil {
 			internalServerError(w, fmt.Errorf("could not fetch campuses: %w", err))
 			return
 		}
 
-		promos, err := getPromosForCampus(db, campus, promo)
+		cursuses, err := getAllCursuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not fetch cursuses: %w", err))
+			return
+		}
+
+		promos, err := getPromosForCampus(db, cursusID, campus, promo)
 		if err != nil {
 			internalServerError(w, fmt.Errorf("could not list promos: %w", err))
 			return
@@ -162,6 +218,7 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 		var totalUsers int64
 		err = db.
 			Model(&models.User{}).
+			Scopes(database.WithCursus(cursusID)).
 			Scopes(database.OnlyRealUsers()).
 			Scopes(database.WithCampus(campus)).
 			Scopes(database.WithPromo(promo)).
@@ -179,14 +236,16 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 		id := getLoggedInUser(r).them.ID
 		err = db.
 			Preload("Campus").
+			Scopes(database.WithCursus(cursusID)).
 			Where("id = ?", id).
-			First(&user).Error
+			Find(&user).Error
 		if err != nil {
 			internalServerError(w, fmt.Errorf("user is not in db: %d: %w", id, err))
 			return
 		}
+		isInCursus := user.ID != 0
 
-		if showMyself && search == "" {
+		if showMyself && search == "" && isInCursus {
 			var myPosition int64
 
 			// We create a SQL query abusing .Select, specifying
@@ -200,6 +259,7 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 					Select(fmt.Sprintf(
 						`*, ROW_NUMBER() OVER (ORDER BY %s DESC) pos`,
 						sorting)).
+					Scopes(database.WithCursus(cursusID)).
 					Scopes(database.OnlyRealUsers()).
 					Scopes(database.WithCampus(campus)).
 					Scopes(database.WithPromo(promo)).
@@ -228,6 +288,7 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 			Offset(offset).
 			Limit(UsersPerPage).
 			Order(sorting + " DESC").
+			Scopes(database.WithCursus(cursusID)).
 			Scopes(database.OnlyRealUsers()).
 			Scopes(database.WithCampus(campus)).
 			Scopes(database.WithPromo(promo)).
@@ -238,11 +299,18 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 			return
 		}
 
+		rankChanges, err := getRankChanges(db, cursusID,
+			getRankColumn(sorting, campus, promo), users)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get rank changes: %w", err))
+			return
+		}
+
 		activeCampusID, _ := strconv.Atoi(campus)
 		userPromo := fmt.Sprintf("%02d/%d",
 			user.BeginAt.Month(),
 			user.BeginAt.Year())
-		gotoMyPositionShown := search == "" &&
+		gotoMyPositionShown := search == "" && isInCursus &&
 			((campus == "" && promo == "") ||
 				(promo == "" && user.Campus.ID == activeCampusID) ||
 				(promo != "" && campus == "" && userPromo == promo) ||
@@ -250,10 +318,11 @@ func handleLeaderboard(db *gorm.DB) http.Handler {
 
 		_ = templates.Leaderboard(users,
 			promos, campuses, activeCampusID,
+			cursuses, cursusID,
 			r.URL, page, totalPages, shownFields,
 			getLoggedInUser(r).them.ID,
 			offset, gotoMyPositionShown,
-			search,
+			search, rankChanges,
 		).Render(r.Context(), w)
 	})
 }
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	maxOpenEntriesPerUser = 5
+	maxLFGNoteLength      = 500
+	maxAvailabilityLength = 100
+	defaultLFGDuration    = 7
+	maxLFGDuration        = 30
+)
+
+// Entries which weren't closed by
+// their author and didn't expire
+func openLFGEntries(db *gorm.DB) *gorm.DB {
+	return db.
+		Where("lfg_entries.closed_at IS NULL").
+		Where("lfg_entries.expires_at > NOW()")
+}
+
+func postLFGEntry(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	me := getLoggedInUser(r).them
+
+	subjectID, err := strconv.Atoi(r.FormValue("subject"))
+	if err != nil {
+		http.Error(w, "invalid subject", http.StatusBadRequest)
+		return
+	}
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil || days <= 0 || days > maxLFGDuration {
+		days = defaultLFGDuration
+	}
+	entry := models.LFGEntry{
+		UserID:       me.ID,
+		SubjectID:    subjectID,
+		CampusID:     me.CampusID,
+		Availability: strings.TrimSpace(r.FormValue("availability")),
+		Note:         strings.TrimSpace(r.FormValue("note")),
+		ExpiresAt:    time.Now().AddDate(0, 0, days),
+	}
+	if len(entry.Availability) > maxAvailabilityLength ||
+		len(entry.Note) > maxLFGNoteLength {
+		http.Error(w, "the note or availability is too long",
+			http.StatusBadRequest)
+		return
+	}
+
+	var subjectCount int64
+	err = db.
+		Model(&models.Subject{}).
+		Where("id = ?", subjectID).
+		Count(&subjectCount).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to find subject: %w", err))
+		return
+	}
+	if subjectCount == 0 {
+		http.Error(w, "invalid subject", http.StatusBadRequest)
+		return
+	}
+
+	var entryCount int64
+	err = db.
+		Model(&models.LFGEntry{}).
+		Scopes(openLFGEntries).
+		Where("user_id = ?", me.ID).
+		Count(&entryCount).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to count entries: %w", err))
+		return
+	}
+	if entryCount >= maxOpenEntriesPerUser {
+		http.Error(w, "too many open entries, close some first",
+			http.StatusBadRequest)
+		return
+	}
+
+	err = db.Create(&entry).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to save entry: %w", err))
+		return
+	}
+	http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+}
+
+func closeLFGEntry(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "invalid entry ID", http.StatusBadRequest)
+		return
+	}
+
+	err = db.
+		Model(&models.LFGEntry{}).
+		Where("id = ?", entryID).
+		Where("user_id = ?", getLoggedInUser(r).them.ID).
+		Where("closed_at IS NULL").
+		Update("closed_at", time.Now()).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to close entry: %w", err))
+		return
+	}
+	http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+}
+
+func handleLFG(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			switch r.FormValue("action") {
+			case "post":
+				postLFGEntry(db, w, r)
+			case "close":
+				closeLFGEntry(db, w, r)
+			default:
+				http.Error(w, "invalid action", http.StatusBadRequest)
+			}
+			return
+		}
+
+		campusID, err := strconv.Atoi(r.URL.Query().Get("campus"))
+		if err != nil {
+			campusID = getLoggedInUser(r).them.CampusID
+		}
+		subjectID, _ := strconv.Atoi(r.URL.Query().Get("subject"))
+
+		var subjects []models.Subject
+		err = db.
+			Model(&models.Subject{}).
+			Order("position, name").
+			Where(database.UnwantedSubjectsCondition).
+			Find(&subjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get subjects: %w", err))
+			return
+		}
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get campuses: %w", err))
+			return
+		}
+
+		query := db.
+			Model(&models.LFGEntry{}).
+			Preload("User").
+			Preload("Subject").
+			Scopes(openLFGEntries).
+			Where("campus_id = ?", campusID)
+		if subjectID != 0 {
+			query = query.Where("subject_id = ?", subjectID)
+		}
+		var entries []models.LFGEntry
+		err = query.
+			Order("created_at DESC").
+			Find(&entries).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get entries: %w", err))
+			return
+		}
+
+		_ = templates.LFG(entries, subjects, subjectID,
+			campuses, campusID, getLoggedInUser(r).them.ID,
+			defaultLFGDuration, maxLFGDuration).Render(r.Context(), w)
+	})
+}
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/internal/users"
+	"gorm.io/gorm"
+)
+
+const (
+	logtimeDateFormat = "2006-01-02"
+	// Custom ranges are fetched from the API, don't make it too long
+	maxLogtimeRange = 366 * 24 * time.Hour
+)
+
+type logtimeResponse struct {
+	Login          string    `json:"login"`
+	Range          string    `json:"range"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	LogtimeMinutes int       `json:"logtime_minutes"`
+}
+
+// Parses ?from= and ?to= as days in the given time zone,
+// and returns the range between both, including the last one
+func getLogtimeRange(r *http.Request, location *time.Location) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation(logtimeDateFormat,
+		r.URL.Query().Get("from"), location)
+	if err != nil {
+		return from, from, errors.New("invalid from date")
+	}
+	to, err := time.ParseInLocation(logtimeDateFormat,
+		r.URL.Query().Get("to"), location)
+	if err != nil {
+		return from, to, errors.New("invalid to date")
+	}
+	to = to.AddDate(0, 0, 1)
+	if !to.After(from) || to.Sub(from) > maxLogtimeRange {
+		return from, to, errors.New("invalid range")
+	}
+	if now := time.Now().In(location); to.After(now) {
+		to = now
+	}
+	return from, to, nil
+}
+
+func logtimeAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := getUserFromQuery(db, r)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+
+		var campus models.Campus
+		err = db.
+			Where("id = ?", user.CampusID).
+			Limit(1).
+			Find(&campus).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find campus: %w", err))
+			return
+		}
+		now := time.Now().In(campus.Location())
+
+		response := logtimeResponse{
+			Login: user.Login,
+			Range: r.URL.Query().Get("range"),
+			To:    now,
+		}
+		var logtime time.Duration
+		switch response.Range {
+		case "today":
+			response.From = users.StartOfDay(now)
+			logtime = user.DailyLogtime
+		case "", "week":
+			response.Range = "week"
+			response.From = users.StartOfWeek(now)
+			logtime = user.WeeklyLogtime
+		case "month":
+			response.From = users.StartOfMonth(now)
+			logtime = user.MonthlyLogtime
+		case "custom":
+			response.From, response.To, err = getLogtimeRange(r, campus.Location())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logtime, err = users.GetLogtimeBetween(user.ID,
+				response.From, response.To)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to get logtime: %w", err))
+				return
+			}
+		default:
+			http.Error(w, "range should be today, week, month or custom",
+				http.StatusBadRequest)
+			return
+		}
+		response.LogtimeMinutes = int(logtime.Minutes())
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
@@ -0,0 +1,330 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	maxMatches      = 50
+	defaultTeamSize = 2
+	maxTeamSize     = 6
+	// Levels further apart than this aren't similar at all
+	matchLevelRange = 2.0
+)
+
+// How much each criterion weighs in the score, out of 100
+const (
+	levelWeight         = 40
+	logtimeWeight       = 30
+	collaborationWeight = 20
+	lookingWeight       = 10
+	// Teaming up this many times is as good as it gets
+	maxTeamsTogether = 3
+	// How far back logtime patterns look
+	logtimePatternDays = 28
+)
+
+type matchParams struct {
+	subjectID int
+	teamSize  int
+	// Only these logins if not empty
+	logins []string
+}
+
+func getMatchParams(r *http.Request) matchParams {
+	query := r.URL.Query()
+	subjectID, _ := strconv.Atoi(query.Get("subject"))
+	teamSize, err := strconv.Atoi(query.Get("size"))
+	if err != nil || teamSize < 2 || teamSize > maxTeamSize {
+		teamSize = defaultTeamSize
+	}
+	var logins []string
+	if rawLogins := query.Get("logins"); rawLogins != "" {
+		logins = strings.Split(rawLogins, ",")
+	}
+	return matchParams{subjectID, teamSize, logins}
+}
+
+func similarity(a, b float64, scale float64) float64 {
+	if scale == 0 {
+		return 1
+	}
+	return math.Max(0, 1-math.Abs(a-b)/scale)
+}
+
+// How many hours a user was logged in on each
+// weekday, starting on Mondays, in the last weeks
+type logtimePattern [7]float64
+
+func getLogtimePatterns(db *gorm.DB, userIDs []int) (map[int]logtimePattern, error) {
+	var days []models.LogtimeDay
+	err := db.
+		Where("user_id IN ?", userIDs).
+		Where("date >= ?", time.Now().AddDate(0, 0, -logtimePatternDays)).
+		Find(&days).Error
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make(map[int]logtimePattern)
+	for _, day := range days {
+		pattern := patterns[day.UserID]
+		pattern[(int(day.Date.Weekday())+6)%7] += day.Logtime.Hours()
+		patterns[day.UserID] = pattern
+	}
+	return patterns, nil
+}
+
+// Whether two people are logged in on the same days,
+// regardless of how much (the cosine similarity)
+func patternSimilarity(a, b logtimePattern) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func scoreMatch(
+	me models.User,
+	myPattern logtimePattern,
+	candidate templates.MatchCandidate,
+	theirPattern logtimePattern,
+	teamSize int,
+) int {
+	levelScore := similarity(me.Level, candidate.Level, matchLevelRange)
+	logtimeScore := (similarity(
+		me.WeeklyLogtime.Hours(), candidate.WeeklyLogtime.Hours(),
+		math.Max(me.WeeklyLogtime.Hours(), candidate.WeeklyLogtime.Hours())) +
+		patternSimilarity(myPattern, theirPattern)) / 2
+	collaborationScore := float64(min(candidate.TeamsTogether,
+		maxTeamsTogether)) / maxTeamsTogether
+	// Groups which would be just big enough with the
+	// user are better than ones with room to spare
+	lookingScore := 0.0
+	if candidate.GroupSize > 0 {
+		lookingScore = float64(candidate.GroupSize+1) / float64(teamSize)
+	}
+	return int(math.Round(levelScore*levelWeight +
+		logtimeScore*logtimeWeight +
+		collaborationScore*collaborationWeight +
+		lookingScore*lookingWeight))
+}
+
+// How many people are in the group the user is creating on the
+// given subject, or 0 if they aren't looking for one
+const groupSizeOnSubject = `COALESCE((SELECT COUNT(*) FROM team_users members
+	WHERE members.team_id = (SELECT team_users.team_id FROM team_users
+		JOIN project_teams ON project_teams.team_id = team_users.team_id
+		JOIN teams ON teams.id = team_users.team_id
+		JOIN projects ON projects.id = project_teams.project_id
+		WHERE team_users.user_id = users.id
+		AND projects.subject_id = @subject
+		AND teams.status = 'creating_group'
+		LIMIT 1)), 0)`
+
+// Teams of the user on the given subject with this status
+const teamOnSubjectCondition = `EXISTS (SELECT 1 FROM team_users
+	JOIN project_teams ON project_teams.team_id = team_users.team_id
+	JOIN teams ON teams.id = team_users.team_id
+	JOIN projects ON projects.id = project_teams.project_id
+	WHERE team_users.user_id = users.id
+	AND projects.subject_id = @subject
+	AND teams.status IN @statuses)`
+
+// Projects of the user on the given subject which they either
+// validated or are still working on
+const projectOnSubjectCondition = `EXISTS (SELECT 1 FROM projects
+	WHERE ` + database.ProjectUserCondition + `
+	AND projects.subject_id = @subject
+	AND (` + database.ValidatedProjectCondition + `
+		OR projects.status IN @statuses))`
+
+// We don't know the prerequisites of subjects, so they're
+// considered available to people of the cursuses they're in
+const subjectAvailableCondition = `EXISTS (SELECT 1 FROM cursus_users
+	WHERE cursus_users.user_id = users.id
+	AND cursus_users.cursus_id IN (SELECT DISTINCT projects.cursus_id
+		FROM projects WHERE projects.subject_id = @subject))`
+
+// Users of the campus who could team up with the given user
+// on the subject, best matches first: those who have it
+// available or are looking for a group on it with room for the
+// user, and who neither validated it nor are in an active team
+// on it.
+func getMatchCandidates(
+	db *gorm.DB,
+	me models.User,
+	params matchParams,
+) ([]templates.MatchCandidate, error) {
+	var candidates []templates.MatchCandidate
+	query := db.
+		Model(&models.User{}).
+		Select(`users.*,
+			(SELECT COUNT(DISTINCT mine.team_id) FROM team_users mine
+				JOIN team_users theirs ON theirs.team_id = mine.team_id
+				WHERE mine.user_id = @me
+				AND theirs.user_id = users.id) teams_together,
+			`+groupSizeOnSubject+` group_size`,
+			map[string]any{
+				"me":      me.ID,
+				"subject": params.subjectID,
+			}).
+		Scopes(database.OnlyMainCursusUsers()).
+		Where("campus_id = ?", me.CampusID).
+		Where("id != ?", me.ID).
+		Where("("+subjectAvailableCondition+" OR "+teamOnSubjectCondition+")",
+			map[string]any{
+				"subject":  params.subjectID,
+				"statuses": []string{"creating_group"},
+			}).
+		Where("id NOT IN (?)", database.UsersWhoValidated(db, params.subjectID)).
+		Where("NOT "+projectOnSubjectCondition, map[string]any{
+			"subject":  params.subjectID,
+			"statuses": []string{"in_progress", "waiting_for_correction"},
+		}).
+		Where("NOT "+teamOnSubjectCondition, map[string]any{
+			"subject":  params.subjectID,
+			"statuses": []string{"in_progress", "waiting_for_correction"},
+		})
+	if len(params.logins) > 0 {
+		query = query.Where("login IN ?", params.logins)
+	} else {
+		query = query.Where("level BETWEEN ? AND ?",
+			me.Level-matchLevelRange, me.Level+matchLevelRange)
+	}
+	err := query.Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	// Full groups can't take the user
+	candidates = slices.DeleteFunc(candidates, func(candidate templates.MatchCandidate) bool {
+		return candidate.GroupSize >= params.teamSize
+	})
+
+	userIDs := []int{me.ID}
+	for _, candidate := range candidates {
+		userIDs = append(userIDs, candidate.ID)
+	}
+	patterns, err := getLogtimePatterns(db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		candidates[i].Score = scoreMatch(me, patterns[me.ID],
+			candidates[i], patterns[candidates[i].ID], params.teamSize)
+	}
+	slices.SortStableFunc(candidates, func(a, b templates.MatchCandidate) int {
+		return b.Score - a.Score
+	})
+	if len(candidates) > maxMatches {
+		candidates = candidates[:maxMatches]
+	}
+	return candidates, nil
+}
+
+// Users who didn't get fetched yet only have
+// what we know from their token
+func getMe(db *gorm.DB, r *http.Request) (models.User, error) {
+	me := getLoggedInUser(r).them
+	user := models.User{ID: me.ID, Login: me.Login, CampusID: me.CampusID}
+	err := db.
+		Where("id = ?", me.ID).
+		Limit(1).
+		Find(&user).Error
+	return user, err
+}
+
+func handleMatching(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me, err := getMe(db, r)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+
+		var subjects []models.Subject
+		err = db.
+			Model(&models.Subject{}).
+			Order("position, name").
+			Where(database.UnwantedSubjectsCondition).
+			Find(&subjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get subjects: %w", err))
+			return
+		}
+
+		params := getMatchParams(r)
+		params.logins = nil
+		var candidates []templates.MatchCandidate
+		if params.subjectID != 0 {
+			candidates, err = getMatchCandidates(db, me, params)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to find teammates: %w", err))
+				return
+			}
+		}
+
+		_ = templates.Matching(subjects, params.subjectID,
+			params.teamSize, candidates).Render(r.Context(), w)
+	})
+}
+
+func matchingExport(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me, err := getMe(db, r)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+		params := getMatchParams(r)
+		if params.subjectID == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		candidates, err := getMatchCandidates(db, me, params)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find teammates: %w", err))
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/csv")
+		w.Header().Add("Content-Disposition", "attachment; filename=shortlist.csv")
+
+		output := csv.NewWriter(w)
+		_ = output.Write([]string{
+			"login", "display_name", "level", "weekly_logtime_hours",
+			"teams_together", "looking_for_group", "score",
+		})
+		for _, candidate := range candidates {
+			_ = output.Write([]string{
+				candidate.Login,
+				candidate.DisplayName,
+				fmt.Sprintf("%.2f", candidate.Level),
+				fmt.Sprintf("%.2f", candidate.WeeklyLogtime.Hours()),
+				strconv.Itoa(candidate.TeamsTogether),
+				strconv.FormatBool(candidate.GroupSize > 0),
+				strconv.Itoa(candidate.Score),
+			})
+		}
+		output.Flush()
+	})
+}
@@ -1,10 +1,13 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/demostanis/42evaluators/internal/database"
 	"github.com/demostanis/42evaluators/internal/models"
@@ -13,16 +16,283 @@ import (
 )
 
 const (
-	usersPerQuery = 10000
+	peersPerPage    = 100
+	maxPeersPerPage = 500
 )
 
+var peerFinderStatuses = []string{
+	"active", "finished", "waiting_for_correction",
+	"creating_group", "in_progress",
+}
+
 func isValidProject(project models.Project) bool {
 	// we need this bunch of conditions since GORM will give us
 	// zeroed projects which don't meet the preload condition...
-	return len(project.Teams) > 0 &&
-		len(project.Teams) > project.ActiveTeam &&
-		len(project.Teams[project.ActiveTeam].Users) > 0 &&
-		project.Teams[project.ActiveTeam].Users[0].User.ID != 0
+	team := project.GetCurrentTeam()
+	return team != nil &&
+		len(team.Users) > 0 &&
+		team.Users[0].User.ID != 0
+}
+
+type peerFinderParams struct {
+	// Subject names, or every subject if empty
+	Subjects []string
+	// -1 for any campus
+	CampusID    int
+	CursusID    int
+	Status      string
+	LevelMin    *float64
+	LevelMax    *float64
+	Promo       string
+	CoalitionID int
+	OnCampus    bool
+	// Only shows projects which changed in this period
+	ActiveWithin time.Duration
+	Page         int
+	PerPage      int
+	// Peers closer to this level show up first
+	MyLevel float64
+}
+
+func parseFloatParam(query url.Values, name string) *float64 {
+	value, err := strconv.ParseFloat(query.Get(name), 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+func getPeerFinderParams(db *gorm.DB, r *http.Request) peerFinderParams {
+	query := r.URL.Query()
+	me := getLoggedInUser(r).them
+	params := peerFinderParams{
+		CursusID: getCursusID(r),
+		Status:   query.Get("status"),
+		LevelMin: parseFloatParam(query, "level_min"),
+		LevelMax: parseFloatParam(query, "level_max"),
+		Promo:    query.Get("promo"),
+		OnCampus: query.Get("on_campus") != "",
+		PerPage:  peersPerPage,
+	}
+
+	if subjects := query.Get("subjects"); subjects != "" {
+		params.Subjects = strings.Split(subjects, ",")
+	}
+
+	campusIDRaw := query.Get("campus")
+	if campusIDRaw == "any" {
+		params.CampusID = -1
+	} else {
+		campusID, err := strconv.Atoi(campusIDRaw)
+		if err != nil {
+			campusID = me.CampusID
+		}
+		params.CampusID = campusID
+	}
+
+	isValidStatus := false
+	for _, possibleStatus := range peerFinderStatuses {
+		if params.Status == possibleStatus {
+			isValidStatus = true
+		}
+	}
+	if !isValidStatus {
+		params.Status = "active"
+	}
+
+	params.CoalitionID, _ = strconv.Atoi(query.Get("coalition"))
+	if days, err := strconv.Atoi(query.Get("active_within")); err == nil && days > 0 {
+		params.ActiveWithin = time.Duration(days) * 24 * time.Hour
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	params.Page = page
+	if perPage, err := strconv.Atoi(query.Get("per_page")); err == nil &&
+		perPage > 0 && perPage <= maxPeersPerPage {
+		params.PerPage = perPage
+	}
+
+	params.MyLevel, _ = getLevel(db, me.ID, params.CursusID)
+	return params
+}
+
+// People who hid their location are never on campus
+const onCampusCondition = `NOT users.hide_location AND
+	EXISTS (SELECT 1 FROM locations
+		WHERE locations.user_id = users.id)`
+
+func withPeerFilters(params peerFinderParams) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.
+			Joins("JOIN users ON "+database.ProjectUserCondition).
+			Joins(`JOIN cursus_users ON cursus_users.user_id = users.id
+				AND cursus_users.cursus_id = projects.cursus_id`).
+			Joins("JOIN subjects ON subjects.id = projects.subject_id").
+			Where("projects.cursus_id = ?", params.CursusID).
+			Where(`users.is_staff = false AND users.is_test = false
+				AND users.is_inactive = false`).
+			Where("projects.subject_id IN (?)", db.
+				Session(&gorm.Session{NewDB: true}).
+				Model(&models.Subject{}).
+				Select("id").
+				Where(database.UnwantedSubjectsCondition))
+
+		if params.Status == "active" {
+			db = db.Where("projects.status != 'finished'")
+		} else {
+			db = db.Where("projects.status = ?", params.Status)
+		}
+		if len(params.Subjects) > 0 {
+			db = db.Where("subjects.name IN ?", params.Subjects)
+		}
+		if params.CampusID != -1 {
+			db = db.Where("users.campus_id = ?", params.CampusID)
+		}
+		if params.LevelMin != nil {
+			db = db.Where("cursus_users.level >= ?", *params.LevelMin)
+		}
+		if params.LevelMax != nil {
+			db = db.Where("cursus_users.level <= ?", *params.LevelMax)
+		}
+		db = db.Scopes(database.WithCursusPromo(params.Promo))
+		if params.CoalitionID != 0 {
+			db = db.Where("users.coalition_id = ?", params.CoalitionID)
+		}
+		if params.OnCampus {
+			db = db.Where(onCampusCondition)
+		}
+		if params.ActiveWithin != 0 {
+			db = db.Where("projects.last_activity_at > ?",
+				time.Now().Add(-params.ActiveWithin))
+		}
+		return db
+	}
+}
+
+// Returns a page of projects matching the filters, the ones
+// of people on campus, around the same level, who were
+// recently active first, along with the total count
+func findPeers(db *gorm.DB, params peerFinderParams) ([]models.Project, int64, error) {
+	var total int64
+	err := db.
+		Model(&models.Project{}).
+		Scopes(withPeerFilters(params)).
+		Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var projects []models.Project
+	err = db.
+		Model(&models.Project{}).
+		// Projects without a user get the one found through their teams
+		Select("projects.*, users.id AS user_id").
+		Preload("Subject").
+		Preload("CurrentTeam.Users.User", database.OnlyRealUsersCondition).
+		Preload("Teams.Users.User", database.OnlyRealUsersCondition).
+		Scopes(withPeerFilters(params)).
+		Order(fmt.Sprintf(`(%s) DESC,
+			ABS(cursus_users.level - %f),
+			projects.last_activity_at DESC`, onCampusCondition, params.MyLevel)).
+		Offset((params.Page - 1) * params.PerPage).
+		Limit(params.PerPage).
+		Find(&projects).Error
+	return projects, total, err
+}
+
+// Returns where each of the users is logged in
+func getHosts(db *gorm.DB, userIDs []int) (map[int]string, error) {
+	var locations []models.Location
+	err := db.
+		Model(&models.Location{}).
+		Joins("JOIN users ON users.id = locations.user_id").
+		Where("locations.user_id IN ?", userIDs).
+		Where("NOT users.hide_location").
+		Find(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+	hosts := make(map[int]string)
+	for _, location := range locations {
+		hosts[location.UserID] = location.Host
+	}
+	return hosts, nil
+}
+
+type peerResponse struct {
+	ProjectID    int       `json:"project_id"`
+	Subject      string    `json:"subject"`
+	Status       string    `json:"status"`
+	FinalMark    int       `json:"final_mark"`
+	Login        string    `json:"login"`
+	Team         string    `json:"team"`
+	Members      []string  `json:"members"`
+	Retries      int       `json:"retries"`
+	Host         string    `json:"host,omitempty"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+type peerFinderResponse struct {
+	Page       int            `json:"page"`
+	TotalPages int            `json:"total_pages"`
+	Total      int64          `json:"total"`
+	Peers      []peerResponse `json:"peers"`
+}
+
+func peerFinderAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := getPeerFinderParams(db, r)
+		projects, total, err := findPeers(db, params)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find peers: %w", err))
+			return
+		}
+
+		userIDs := make([]int, 0, len(projects))
+		for _, project := range projects {
+			userIDs = append(userIDs, project.UserID)
+		}
+		hosts, err := getHosts(db, userIDs)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get locations: %w", err))
+			return
+		}
+
+		response := peerFinderResponse{
+			Page:       params.Page,
+			TotalPages: max(1, 1+(int(total)-1)/params.PerPage),
+			Total:      total,
+			Peers:      make([]peerResponse, 0, len(projects)),
+		}
+		for _, project := range projects {
+			peer := peerResponse{
+				ProjectID:    project.ID,
+				Subject:      project.Subject.Name,
+				Status:       project.Status,
+				FinalMark:    project.FinalMark,
+				Retries:      project.Occurrence,
+				Host:         hosts[project.UserID],
+				LastActivity: project.LastActivityAt,
+				Members:      make([]string, 0),
+			}
+			if team := project.GetCurrentTeam(); team != nil {
+				peer.Team = team.Name
+				for _, teamUser := range team.Users {
+					if teamUser.User.ID == project.UserID {
+						peer.Login = teamUser.User.Login
+					}
+					peer.Members = append(peer.Members, teamUser.User.Login)
+				}
+			}
+			response.Peers = append(response.Peers, peer)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
 }
 
 func handlePeerFinder(db *gorm.DB) http.Handler {
@@ -45,92 +315,71 @@ func handlePeerFinder(db *gorm.DB) http.Handler {
 			return
 		}
 
-		var campusID int
-		campusIDRaw := r.URL.Query().Get("campus")
-		if campusIDRaw == "any" {
-			campusID = -1
-		} else {
-			campusID, err = strconv.Atoi(campusIDRaw)
-			if err != nil {
-				campusID = getLoggedInUser(r).them.CampusID
-			}
+		cursuses, err := getAllCursuses(db)
+		if err != nil {
+			internalServerError(w, err)
+			return
 		}
 
-		status := r.URL.Query().Get("status")
-		if status == "" {
-			status = "active"
-		}
-		isValidStatus := false
-		for _, possibleStatus := range []string{
-			"active", "finished", "waiting_for_correction",
-			"creating_group", "in_progress",
-		} {
-			if status == possibleStatus {
-				isValidStatus = true
-			}
-		}
-		if !isValidStatus {
-			status = "active"
+		var coalitions []models.Coalition
+		err = db.
+			Model(&models.Coalition{}).
+			Order("name").
+			Find(&coalitions).Error
+		if err != nil {
+			internalServerError(w, err)
+			return
 		}
 
-		var wantedSubjects []string
-		wantedSubjectsRaw := r.URL.Query().Get("subjects")
-		if wantedSubjectsRaw != "" {
-			wantedSubjects = strings.Split(wantedSubjectsRaw, ",")
-		} else {
-			for _, subject := range subjects {
-				wantedSubjects = append(wantedSubjects, subject.Name)
+		params := getPeerFinderParams(db, r)
+
+		var promos []templates.Promo
+		if params.CampusID != -1 {
+			promos, err = getPromosForCampus(db, params.CursusID,
+				strconv.Itoa(params.CampusID), params.Promo)
+			if err != nil {
+				internalServerError(w, err)
+				return
 			}
 		}
 
 		checkedSubjects := make(map[string]bool)
-		for _, subject := range wantedSubjects {
+		for _, subject := range subjects {
+			checkedSubjects[subject.Name] = len(params.Subjects) == 0
+		}
+		for _, subject := range params.Subjects {
 			checkedSubjects[subject] = true
 		}
 
-		withStatus := func(search string) func(db *gorm.DB) *gorm.DB {
-			return func(db *gorm.DB) *gorm.DB {
-				if status == "active" {
-					return db.
-						Where("status != 'finished'")
-				}
-				return db.
-					Where("status = ?", status)
-			}
+		projects, total, err := findPeers(db, params)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find peers: %w", err))
+			return
 		}
+		totalPages := max(1, 1+(int(total)-1)/params.PerPage)
 
 		projectsMap := make(map[int][]models.Project)
-
-		preloadCondition := ""
-		if campusID != -1 {
-			preloadCondition += fmt.Sprintf("campus_id = %d AND ", campusID)
-		}
-		preloadCondition += database.OnlyRealUsersCondition
-
-		var projects []models.Project
-		db.
-			Preload("Teams.Users.User",
-				preloadCondition).
-			Preload("Subject", "name IN ? AND "+database.UnwantedSubjectsCondition,
-				wantedSubjects).
-			Scopes(withStatus(status)).
-			Model(&models.Project{}).
-			// We need to fetch by batches of users, else GORM
-			// generates way too large queries...
-			FindInBatches(&projects, usersPerQuery,
-				func(db *gorm.DB, batch int) error {
-					for _, project := range projects {
-						if isValidProject(project) {
-							projectsMap[project.Subject.ID] = append(
-								projectsMap[project.Subject.ID], project)
-						}
-					}
-					return nil
-				})
+		for _, project := range projects {
+			if isValidProject(project) {
+				projectsMap[project.Subject.ID] = append(
+					projectsMap[project.Subject.ID], project)
+			}
+		}
 
 		_ = templates.PeerFinder(
 			subjects, projectsMap, checkedSubjects,
-			status, campuses, campusID,
+			params.Status, campuses, params.CampusID,
+			cursuses, params.CursusID,
+			templates.PeerFilters{
+				LevelMin:     params.LevelMin,
+				LevelMax:     params.LevelMax,
+				Promos:       promos,
+				Coalitions:   coalitions,
+				CoalitionID:  params.CoalitionID,
+				OnCampus:     params.OnCampus,
+				ActiveWithin: int(params.ActiveWithin.Hours() / 24),
+			},
+			r.URL, params.Page, totalPages,
 		).Render(r.Context(), w)
 	})
 }
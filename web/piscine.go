@@ -0,0 +1,175 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	piscineDays   = "C Piscine C %"
+	piscineRushes = "C Piscine Rush %"
+	piscineExams  = "C Piscine %Exam%"
+)
+
+const validatedPiscineSubjects = `(SELECT COUNT(DISTINCT projects.subject_id)
+	FROM projects
+	JOIN subjects ON subjects.id = projects.subject_id
+	WHERE ` + database.ProjectUserCondition + `
+	AND projects.cursus_id = ?
+	AND ` + database.ValidatedProjectCondition + `
+	AND subjects.name LIKE ?)`
+
+func getPiscineux(
+	db *gorm.DB,
+	campus string,
+	piscine string,
+	sorting string,
+) ([]templates.Piscineux, error) {
+	var piscineux []templates.Piscineux
+
+	err := db.
+		Model(&models.User{}).
+		Select(`users.*,
+			`+validatedPiscineSubjects+` validated_days,
+			`+validatedPiscineSubjects+` validated_rushes,
+			`+validatedPiscineSubjects+` validated_exams,
+			(SELECT logtime FROM cursus_users
+				WHERE cursus_users.user_id = users.id
+				AND cursus_users.cursus_id = ?) logtime`,
+			models.PiscineCursusID, piscineDays,
+			models.PiscineCursusID, piscineRushes,
+			models.PiscineCursusID, piscineExams,
+			models.PiscineCursusID).
+		Scopes(database.WithCursus(models.PiscineCursusID)).
+		Scopes(database.OnlyRealUsers()).
+		Scopes(database.WithCampus(campus)).
+		Scopes(database.WithPromo(piscine)).
+		Order(sorting + " DESC").
+		Find(&piscineux).Error
+	return piscineux, err
+}
+
+type piscineParams struct {
+	campus   string
+	piscine  string
+	piscines []templates.Promo
+	sorting  string
+}
+
+// Defaults to the logged-in user's campus, and to
+// the latest piscine which happened in it
+func getPiscineParams(db *gorm.DB, r *http.Request) (*piscineParams, error) {
+	params := piscineParams{
+		campus:  r.URL.Query().Get("campus"),
+		piscine: r.URL.Query().Get("piscine"),
+		sorting: r.URL.Query().Get("sort"),
+	}
+	if params.campus == "" {
+		params.campus = strconv.Itoa(getLoggedInUser(r).them.CampusID)
+	}
+	if params.sorting == "" ||
+		!canSortOn(templates.PiscineToggleableFields, params.sorting) {
+		params.sorting = "level"
+	}
+
+	var err error
+	params.piscines, err = getPromosForCampus(db,
+		models.PiscineCursusID, params.campus, params.piscine)
+	if err != nil {
+		return nil, err
+	}
+	if params.piscine == "" && len(params.piscines) > 0 {
+		params.piscine = params.piscines[0].Name
+		params.piscines[0].Active = true
+	}
+	return &params, nil
+}
+
+func handlePiscine(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, err := getPiscineParams(db, r)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not list piscines: %w", err))
+			return
+		}
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not fetch campuses: %w", err))
+			return
+		}
+
+		var piscineux []templates.Piscineux
+		if params.piscine != "" {
+			piscineux, err = getPiscineux(db,
+				params.campus, params.piscine, params.sorting)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to list piscineux: %w", err))
+				return
+			}
+		}
+
+		shownFields := getShownFields(templates.PiscineToggleableFields,
+			[]string{"level", "validated_days", "validated_exams", "logtime"},
+			r.URL.Query().Get("fields"))
+		activeCampusID, _ := strconv.Atoi(params.campus)
+
+		_ = templates.Piscine(piscineux,
+			params.piscines, campuses, activeCampusID,
+			r.URL, shownFields,
+			getLoggedInUser(r).them.ID,
+			isStaff(getLoggedInUser(r)),
+		).Render(r.Context(), w)
+	})
+}
+
+func piscineExport(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, err := getPiscineParams(db, r)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not list piscines: %w", err))
+			return
+		}
+		if params.piscine == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		piscineux, err := getPiscineux(db,
+			params.campus, params.piscine, params.sorting)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to list piscineux: %w", err))
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/csv")
+		w.Header().Add("Content-Disposition", "attachment; filename=piscine.csv")
+
+		output := csv.NewWriter(w)
+		_ = output.Write([]string{
+			"position", "login", "display_name", "level",
+			"validated_days", "validated_rushes",
+			"validated_exams", "logtime_hours",
+		})
+		for i, piscineu := range piscineux {
+			_ = output.Write([]string{
+				strconv.Itoa(i + 1),
+				piscineu.Login,
+				piscineu.DisplayName,
+				fmt.Sprintf("%.2f", piscineu.Level),
+				strconv.Itoa(piscineu.ValidatedDays),
+				strconv.Itoa(piscineu.ValidatedRushes),
+				strconv.Itoa(piscineu.ValidatedExams),
+				fmt.Sprintf("%.2f", piscineu.Logtime.Hours()),
+			})
+		}
+		output.Flush()
+	})
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/demostanis/42evaluators/internal/calculator"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/internal/projects"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPlansPerUser    = 20
+	maxProjectsPerPlan = 50
+)
+
+func getPlanProjects(plan models.CalculatorPlan) []calculator.Project {
+	projectList := make([]calculator.Project, 0, len(plan.Projects))
+	for _, project := range plan.Projects {
+		projectList = append(projectList, calculator.Project{
+			XP:             project.XP,
+			Mark:           project.Mark,
+			CoalitionBonus: project.CoalitionBonus,
+		})
+	}
+	return projectList
+}
+
+func calculatePlan(plan models.CalculatorPlan) calculator.Result {
+	return calculator.Calculate(projects.XPData, plan.Level, getPlanProjects(plan))
+}
+
+func getPlanComparisons(db *gorm.DB, userID int, cursusID int) ([]templates.PlanComparison, error) {
+	var plans []models.CalculatorPlan
+	err := db.
+		Model(&models.CalculatorPlan{}).
+		Where("user_id = ?", userID).
+		Where("cursus_id = ?", cursusID).
+		Order("created_at").
+		Find(&plans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	comparisons := make([]templates.PlanComparison, 0, len(plans))
+	for _, plan := range plans {
+		comparisons = append(comparisons, templates.PlanComparison{
+			Plan:   plan,
+			Result: calculatePlan(plan),
+		})
+	}
+	return comparisons, nil
+}
+
+func savePlan(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	userID := getLoggedInUser(r).them.ID
+
+	var plan models.CalculatorPlan
+	err := json.NewDecoder(r.Body).Decode(&plan)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid plan: %v", err),
+			http.StatusBadRequest)
+		return
+	}
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" || len(plan.Projects) == 0 ||
+		len(plan.Projects) > maxProjectsPerPlan {
+		http.Error(w, "plans need a name and projects",
+			http.StatusBadRequest)
+		return
+	}
+	err = calculator.Validate(projects.XPData, plan.Level, getPlanProjects(plan))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var planCount int64
+	err = db.
+		Model(&models.CalculatorPlan{}).
+		Where("user_id = ?", userID).
+		Count(&planCount).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to count plans: %w", err))
+		return
+	}
+	if planCount >= maxPlansPerUser {
+		http.Error(w, "too many plans, delete some first",
+			http.StatusBadRequest)
+		return
+	}
+
+	plan.ID = 0
+	plan.UserID = userID
+	if plan.CursusID == 0 {
+		plan.CursusID = models.MainCursusID
+	}
+	err = db.Create(&plan).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to save plan: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(templates.PlanComparison{
+		Plan:   plan,
+		Result: calculatePlan(plan),
+	})
+}
+
+func deletePlan(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	planID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid plan ID", http.StatusBadRequest)
+		return
+	}
+
+	err = db.
+		Where("id = ?", planID).
+		Where("user_id = ?", getLoggedInUser(r).them.ID).
+		Delete(&models.CalculatorPlan{}).Error
+	if err != nil {
+		internalServerError(w, fmt.Errorf("failed to delete plan: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func plansAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			cursusID := getCursusID(r)
+			plans, err := getPlanComparisons(db,
+				getLoggedInUser(r).them.ID, cursusID)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to get plans: %w", err))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(plans)
+		case http.MethodPost:
+			savePlan(db, w, r)
+		case http.MethodDelete:
+			deletePlan(db, w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
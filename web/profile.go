@@ -0,0 +1,234 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/calculator"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/internal/projects"
+	"github.com/demostanis/42evaluators/internal/users"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+// Projects fetched before we stored their user
+// can only be found through their teams
+func projectsOf(db *gorm.DB, userID int) *gorm.DB {
+	return db.
+		Where("projects.user_id = ?", userID).
+		Or("projects.user_id = 0 AND projects.id IN (?)", db.
+			Table("project_teams").
+			Select("project_teams.project_id").
+			Joins("JOIN team_users ON team_users.team_id = project_teams.team_id").
+			Where("team_users.user_id = ?", userID))
+}
+
+func getTeammates(db *gorm.DB, userID int) ([]templates.Teammate, error) {
+	var teammates []templates.Teammate
+	err := db.
+		Table("team_users mine").
+		Select("users.*, COUNT(DISTINCT mine.team_id) teams").
+		Joins(`JOIN team_users theirs ON theirs.team_id = mine.team_id
+			AND theirs.user_id != mine.user_id`).
+		Joins("JOIN users ON users.id = theirs.user_id").
+		Where("mine.user_id = ?", userID).
+		Group("users.id").
+		Order("teams DESC, users.login").
+		Scan(&teammates).Error
+	return teammates, err
+}
+
+// Returns every team the user was in, by subject. Teams can't
+// be found from the user's projects since those are shared.
+func getAttempts(db *gorm.DB, userID int) (map[int][]models.Team, error) {
+	var teams []models.Team
+	err := db.
+		Model(&models.Team{}).
+		Joins("JOIN team_users ON team_users.team_id = teams.id").
+		Where("team_users.user_id = ?", userID).
+		Order("teams.id").
+		Find(&teams).Error
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDs := make([]int, 0, len(teams))
+	for _, team := range teams {
+		projectIDs = append(projectIDs, team.ProjectID)
+	}
+	var projectSubjects []struct {
+		ID        int
+		SubjectID int
+	}
+	err = db.
+		Model(&models.Project{}).
+		Select("id, subject_id").
+		Where("id IN ?", projectIDs).
+		Scan(&projectSubjects).Error
+	if err != nil {
+		return nil, err
+	}
+	subjectOf := make(map[int]int)
+	for _, project := range projectSubjects {
+		subjectOf[project.ID] = project.SubjectID
+	}
+
+	attempts := make(map[int][]models.Team)
+	for _, team := range teams {
+		subjectID := subjectOf[team.ProjectID]
+		attempts[subjectID] = append(attempts[subjectID], team)
+	}
+	return attempts, nil
+}
+
+// Weeks shown in the logtime calendar
+const logtimeCalendarWeeks = 53
+
+// Returns the user's logtime for each day of the last
+// weeks, as columns of weeks starting on Mondays
+func getLogtimeCalendar(db *gorm.DB, user models.User) ([][]templates.LogtimeCell, error) {
+	location := user.Campus.Location()
+	today := users.StartOfDay(time.Now().In(location))
+	firstDay := users.StartOfWeek(today).AddDate(0, 0, -7*(logtimeCalendarWeeks-1))
+
+	var days []models.LogtimeDay
+	err := db.
+		Model(&models.LogtimeDay{}).
+		Where("user_id = ?", user.ID).
+		Where("date >= ?", firstDay.Format(time.DateOnly)).
+		Find(&days).Error
+	if err != nil {
+		return nil, err
+	}
+	logtimes := make(map[string]time.Duration)
+	for _, day := range days {
+		logtimes[day.Date.Format(time.DateOnly)] = day.Logtime
+	}
+
+	weeks := make([][]templates.LogtimeCell, 0, logtimeCalendarWeeks)
+	for day := firstDay; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Monday {
+			weeks = append(weeks, make([]templates.LogtimeCell, 0, 7))
+		}
+		week := &weeks[len(weeks)-1]
+		*week = append(*week, templates.LogtimeCell{
+			Date:    day,
+			Logtime: logtimes[day.Format(time.DateOnly)],
+		})
+	}
+	return weeks, nil
+}
+
+func handleProfile(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := strings.Trim(strings.TrimPrefix(r.URL.Path, "/users/"), "/")
+		if login == "" {
+			login = getLoggedInUser(r).them.Login
+		}
+
+		var user models.User
+		err := db.
+			Preload("Campus").
+			Preload("Coalition").
+			Preload("Title").
+			Where("login = ?", login).
+			Where("is_test = false").
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+
+		isMe := user.ID == getLoggedInUser(r).them.ID
+		var location string
+		err = db.
+			Model(&models.Location{}).
+			Select("host").
+			Where("user_id = ?", user.ID).
+			Limit(1).
+			Find(&location).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get location: %w", err))
+			return
+		}
+
+		var userProjects []models.Project
+		err = db.
+			Preload("Subject").
+			Joins("JOIN subjects ON subjects.id = projects.subject_id").
+			Where(projectsOf(db, user.ID)).
+			Order("subjects.position, subjects.name").
+			Find(&userProjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get projects: %w", err))
+			return
+		}
+		// Teammates share teams, and teams are only
+		// linked to one of their members' projects
+		seenSubjects := make(map[int]bool)
+		uniqueProjects := make([]models.Project, 0, len(userProjects))
+		for _, project := range userProjects {
+			if !seenSubjects[project.SubjectID] {
+				seenSubjects[project.SubjectID] = true
+				uniqueProjects = append(uniqueProjects, project)
+			}
+		}
+
+		attempts, err := getAttempts(db, user.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get attempts: %w", err))
+			return
+		}
+
+		teammates, err := getTeammates(db, user.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get teammates: %w", err))
+			return
+		}
+
+		var snapshots []models.LevelSnapshot
+		err = db.
+			Model(&models.LevelSnapshot{}).
+			Where("user_id = ?", user.ID).
+			Where("cursus_id = ?", models.MainCursusID).
+			Order("date").
+			Find(&snapshots).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get level history: %w", err))
+			return
+		}
+
+		pointsHistory, err := getPointsHistory(db, user.ID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get points history: %w", err))
+			return
+		}
+
+		logtimeCalendar, err := getLogtimeCalendar(db, user)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get logtime history: %w", err))
+			return
+		}
+
+		_ = templates.Profile(templates.ProfileData{
+			User:            user,
+			XPToNextLevel:   calculator.XPToNextLevel(projects.XPData, user.Level),
+			Location:        location,
+			IsMe:            isMe,
+			Projects:        uniqueProjects,
+			Attempts:        attempts,
+			Teammates:       teammates,
+			LevelSnapshots:  snapshots,
+			PointsHistory:   pointsHistory,
+			LogtimeCalendar: logtimeCalendar,
+		}).Render(r.Context(), w)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/a-h/templ"
 	"github.com/demostanis/42evaluators/internal/api"
@@ -27,6 +29,45 @@ func loggedInUsersOnly(handler http.Handler) http.Handler {
 	})
 }
 
+// Admins are listed by login, separated by commas,
+// in the ADMINS environment variable.
+func isAdmin(user *LoggedInUser) bool {
+	if user == nil {
+		return false
+	}
+	for _, admin := range strings.Split(os.Getenv("ADMINS"), ",") {
+		if admin != "" && admin == user.them.Login {
+			return true
+		}
+	}
+	return false
+}
+
+func adminsOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(getLoggedInUser(r)) {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Campus staff, according to the intra, and admins
+func isStaff(user *LoggedInUser) bool {
+	return user != nil && (user.them.IsStaff || isAdmin(user))
+}
+
+func staffOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isStaff(getLoggedInUser(r)) {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func withURL(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), templates.UrlCtxKey, r.URL.Path)
@@ -37,14 +78,41 @@ func withURL(handler http.Handler) http.Handler {
 func Run(db *gorm.DB) {
 	http.Handle("/", withURL(handleIndex(db)))
 	http.Handle("/leaderboard/", withURL(loggedInUsersOnly(handleLeaderboard(db))))
+	http.Handle("/leaderboard/history/", withURL(loggedInUsersOnly(handleLevelHistory(db))))
+	http.Handle("/users/", withURL(loggedInUsersOnly(handleProfile(db))))
+	http.Handle("/collaborations/", withURL(loggedInUsersOnly(handleCollaborations(db))))
+	http.Handle("/collaborations.graphml", withURL(loggedInUsersOnly(staffOnly(collaborationsExport(db)))))
+	http.Handle("/collaborations.json", withURL(loggedInUsersOnly(staffOnly(collaborationsExport(db)))))
+	http.Handle("/api/collaborations", loggedInUsersOnly(collaborationsAPI(db)))
+	http.Handle("/api/logtime", loggedInUsersOnly(logtimeAPI(db)))
+	http.Handle("/api/sessions", loggedInUsersOnly(sessionsAPI(db)))
+	http.Handle("/graph/", withURL(loggedInUsersOnly(handleGraph(db))))
 	http.Handle("/peerfinder/", withURL(loggedInUsersOnly(handlePeerFinder(db))))
+	http.Handle("/lfg/", withURL(loggedInUsersOnly(handleLFG(db))))
+	http.Handle("/peerfinder/match/", withURL(loggedInUsersOnly(handleMatching(db))))
+	http.Handle("/peerfinder/match.csv", withURL(loggedInUsersOnly(matchingExport(db))))
+	http.Handle("/api/peerfinder", loggedInUsersOnly(peerFinderAPI(db)))
 	http.Handle("/calculator/", withURL(loggedInUsersOnly(handleCalculator(db))))
+	http.Handle("/api/calculator", loggedInUsersOnly(calculatorAPI(db)))
+	http.Handle("/api/calculator/plans", loggedInUsersOnly(plansAPI(db)))
+	http.Handle("/piscine/", withURL(loggedInUsersOnly(handlePiscine(db))))
+	http.Handle("/piscine.csv", withURL(loggedInUsersOnly(staffOnly(piscineExport(db)))))
+	http.Handle("/subjects/", withURL(loggedInUsersOnly(handleSubjectStats(db))))
+	http.Handle("/find-evaluator/", withURL(loggedInUsersOnly(handleFindEvaluator(db))))
+	http.Handle("/evaluators/", withURL(loggedInUsersOnly(handleEvaluators(db))))
+	http.Handle("/economy/", withURL(loggedInUsersOnly(handleEconomy(db))))
 	http.Handle("/blackhole/", withURL(loggedInUsersOnly(handleBlackhole(db))))
 	http.Handle("/blackhole.json", withURL(loggedInUsersOnly(blackholeMap(db))))
 	http.Handle("/clusters/", withURL(loggedInUsersOnly(handleClusters())))
+	http.Handle("/clusters/stats/", withURL(loggedInUsersOnly(handleClusterStats(db))))
+	http.Handle("/clusters/free/", withURL(loggedInUsersOnly(handleFreeSeats(db))))
+	http.Handle("/clusters/free.json", withURL(loggedInUsersOnly(freeSeatsAPI(db))))
 	http.Handle("/clusters.live", withURL(loggedInUsersOnly(clustersWs(db))))
+	http.Handle("/api/whereis", loggedInUsersOnly(whereisAPI(db)))
+	http.Handle("/settings/location", loggedInUsersOnly(handleLocationPrivacy(db)))
 	http.Handle("/stats/", withURL(loggedInUsersOnly(templ.Handler(templates.Stats(&api.APIStats)))))
 	http.Handle("/stats.live", withURL(loggedInUsersOnly(statsWs(db))))
+	http.Handle("/admin/users/", withURL(loggedInUsersOnly(adminsOnly(handleUserChanges(db)))))
 	http.Handle("/useful-links/", withURL(loggedInUsersOnly(templ.Handler(templates.Links()))))
 
 	http.Handle("/static/", handleStatic())
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSessions         = 1000
+	defaultSessionsDays = 7
+)
+
+// Returns the sessions of ?user= (a login), or of ?host= in
+// ?campus= (or the user's campus), which began between
+// ?from= and ?to=, or during the last week, latest first.
+// Sessions of people who hid their location are left out.
+func sessionsAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		login := query.Get("user")
+		host := query.Get("host")
+		if (login == "") == (host == "") {
+			http.Error(w, "either user or host should be given",
+				http.StatusBadRequest)
+			return
+		}
+
+		me := getLoggedInUser(r).them
+		campusID, err := strconv.Atoi(query.Get("campus"))
+		if err != nil {
+			campusID = me.CampusID
+		}
+		sessions := db.
+			Model(&models.LocationSession{}).
+			Select("location_sessions.*").
+			Joins("JOIN users ON users.id = location_sessions.user_id").
+			Where("(NOT users.hide_location OR users.id = ?)", me.ID)
+		if login != "" {
+			user, err := getUserFromQuery(db, r)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+				return
+			}
+			campusID = user.CampusID
+			sessions = sessions.Where("location_sessions.user_id = ?", user.ID)
+		} else {
+			sessions = sessions.
+				Where("location_sessions.campus_id = ?", campusID).
+				Where("location_sessions.host = ?", host)
+		}
+
+		var campus models.Campus
+		err = db.
+			Where("id = ?", campusID).
+			Limit(1).
+			Find(&campus).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find campus: %w", err))
+			return
+		}
+
+		to := time.Now()
+		from := to.AddDate(0, 0, -defaultSessionsDays)
+		if query.Has("from") || query.Has("to") {
+			from, to, err = getLogtimeRange(r, campus.Location())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		result := make([]models.LocationSession, 0)
+		err = sessions.
+			Where("location_sessions.begin_at >= ? AND location_sessions.begin_at < ?",
+				from, to).
+			Order("location_sessions.begin_at DESC").
+			Limit(maxSessions).
+			Find(&result).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get sessions: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
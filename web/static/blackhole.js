@@ -27,7 +27,7 @@ const createUserElem = user => {
 	const cardTitle = document.createElement("h2");
 	const cardTitleTitle = document.createElement("a");
 	cardTitle.classList.add("card-title");
-	cardTitleTitle.href = "https://profile.intra.42.fr/users/" + user.login;
+	cardTitleTitle.href = "/users/" + user.login;
 	cardTitleTitle.textContent = user.login;
 	cardTitle.appendChild(cardTitleTitle);
 
@@ -297,12 +297,12 @@ function renderBlackholeMap(blackholeMap) {
 		if (currentTarget && event.buttons == 0) {
 			if (lastButtons == 4) {
 				const a = document.createElement("a");
-				a.href = "https://profile.intra.42.fr/users/"
+				a.href = "/users/"
 					+ currentTarget.object.user.login;
 				a.target = "_blank";
 				a.click();
 			} else if (lastButtons == 1) {
-				window.location.href = "https://profile.intra.42.fr/users/"
+				window.location.href = "/users/"
 					+ currentTarget.object.user.login;
 			}
 		}
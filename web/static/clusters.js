@@ -22,8 +22,10 @@ const handleNewImages = () => {
 			const popupTitle = document.createElement("h2");
 			const popupTitleTitle = document.createElement("a");
 			popupTitle.classList.add("popup-title", "text-center");
-			popupTitleTitle.href = "https://profile.intra.42.fr/users/" + login;
-			popupTitleTitle.textContent = login;
+			// People who hid their location have no login
+			if (login)
+				popupTitleTitle.href = "/users/" + login;
+			popupTitleTitle.textContent = login || "Someone";
 			popupTitle.appendChild(popupTitleTitle);
 
 			const popupBodyBody = document.createElement("p");
@@ -97,3 +99,75 @@ ws.onmessage = message => {
 			elem.setAttribute("href", data.image);
 	}
 }
+
+// Draws a rectangle around the seat of the host
+const highlightSeat = host => {
+	document.querySelector("#highlight")?.remove();
+	const elem = document.all[host]?.[1];
+	if (!elem) return false;
+
+	const bbox = elem.getBBox();
+	const margin = bbox.width / 4;
+	const rect = document.createElementNS("http://www.w3.org/2000/svg", "rect");
+	rect.id = "highlight";
+	rect.setAttribute("x", bbox.x - margin);
+	rect.setAttribute("y", bbox.y - margin);
+	rect.setAttribute("width", bbox.width + margin*2);
+	rect.setAttribute("height", bbox.height + margin*2);
+	rect.setAttribute("rx", margin);
+	rect.setAttribute("fill", "none");
+	rect.setAttribute("stroke", "red");
+	rect.setAttribute("stroke-width", margin);
+	rect.classList.add("animate-pulse");
+	if (elem.hasAttribute("transform"))
+		rect.setAttribute("transform", elem.getAttribute("transform"));
+	elem.parentNode.appendChild(rect);
+	return true;
+}
+
+// Looks up where the login is, switching to its cluster
+// if needed (the search is then done again on load)
+const findLogin = async login => {
+	const status = document.querySelector("#whereis-status");
+	status.textContent = "";
+	const response = await fetch("/api/whereis?login="
+		+ encodeURIComponent(login));
+	if (response.status == 404) {
+		status.textContent = login + " is not on campus";
+		return;
+	}
+	if (!response.ok) {
+		status.textContent = "Something went wrong";
+		return;
+	}
+	const data = await response.json();
+	const search = new URLSearchParams(window.location.search);
+	if (data.cluster_id && search.get("cluster") != data.cluster_id) {
+		search.set("cluster", data.cluster_id);
+		search.set("find", login);
+		window.location.search = search;
+		return;
+	}
+
+	const since = new Date(data.since).toLocaleTimeString([],
+		{ hour: "2-digit", minute: "2-digit" });
+	status.textContent = data.login + " is at " + data.host
+		+ " since " + since;
+	if (!data.cluster_id || !highlightSeat(data.host))
+		status.textContent += " (not on any map)";
+}
+
+window.addEventListener("DOMContentLoaded", () => {
+	const form = document.querySelector("#whereis");
+	form.addEventListener("submit", event => {
+		event.preventDefault();
+		const login = form.login.value.trim();
+		if (login) findLogin(login);
+	});
+
+	const login = new URLSearchParams(window.location.search).get("find");
+	if (login) {
+		form.login.value = login;
+		findLogin(login);
+	}
+});
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/demostanis/42evaluators/internal/database"
+	"github.com/demostanis/42evaluators/internal/models"
+	"github.com/demostanis/42evaluators/web/templates"
+	"gorm.io/gorm"
+)
+
+const subjectAggregates = `COUNT(*) users,
+	COUNT(*) FILTER (WHERE projects.status = 'finished') finished,
+	COUNT(*) FILTER (WHERE ` + database.ValidatedProjectCondition + `) validated,
+	AVG(projects.occurrence) FILTER (WHERE ` + database.ValidatedProjectCondition + `) average_retries,
+	PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY
+		EXTRACT(EPOCH FROM projects.marked_at - first_teams.created_at))
+		FILTER (WHERE ` + database.ValidatedProjectCondition + `
+		AND projects.marked_at > first_teams.created_at) median_seconds`
+
+func projectsOfSubject(subjectID int, campusID int, promo string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.
+			Joins("JOIN users ON "+database.ProjectUserCondition).
+			Joins(`LEFT JOIN (SELECT project_teams.project_id,
+					MIN(teams.created_at) created_at
+				FROM project_teams
+				JOIN teams ON teams.id = project_teams.team_id
+				GROUP BY project_teams.project_id) first_teams
+				ON first_teams.project_id = projects.id`).
+			Where("projects.subject_id = ?", subjectID).
+			Where(database.OnlyRealUsersCondition)
+		if campusID != -1 {
+			db = db.Where("users.campus_id = ?", campusID)
+		}
+		if promo != "" {
+			db = db.
+				Joins(`JOIN cursus_users ON cursus_users.user_id = users.id
+					AND cursus_users.cursus_id = projects.cursus_id`).
+				Scopes(database.WithCursusPromo(promo))
+		}
+		return db
+	}
+}
+
+func handleSubjectStats(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me := getLoggedInUser(r).them
+
+		var campusID int
+		campusIDRaw := r.URL.Query().Get("campus")
+		if campusIDRaw == "any" {
+			campusID = -1
+		} else {
+			var err error
+			campusID, err = strconv.Atoi(campusIDRaw)
+			if err != nil {
+				campusID = me.CampusID
+			}
+		}
+		promo := r.URL.Query().Get("promo")
+		if campusID == -1 {
+			// Promos are per campus
+			promo = ""
+		}
+
+		var subjects []models.Subject
+		err := db.
+			Model(&models.Subject{}).
+			Order("position, name").
+			Where(database.UnwantedSubjectsCondition).
+			Find(&subjects).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get subjects: %w", err))
+			return
+		}
+		subjectID, err := strconv.Atoi(r.URL.Query().Get("subject"))
+		if err != nil && len(subjects) > 0 {
+			subjectID = subjects[0].ID
+		}
+
+		campuses, err := getAllCampuses(db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("could not fetch campuses: %w", err))
+			return
+		}
+
+		var promos []templates.Promo
+		if campusID != -1 {
+			promos, err = getPromosForCampus(db, models.MainCursusID,
+				strconv.Itoa(campusID), promo)
+			if err != nil {
+				internalServerError(w, fmt.Errorf("could not list promos: %w", err))
+				return
+			}
+		}
+
+		scope := projectsOfSubject(subjectID, campusID, promo)
+
+		var stats templates.SubjectStats
+		err = db.
+			Model(&models.Project{}).
+			Select(subjectAggregates).
+			Scopes(scope).
+			Scan(&stats).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get subject stats: %w", err))
+			return
+		}
+
+		var marks []templates.MarksBucket
+		err = db.
+			Model(&models.Project{}).
+			Select("LEAST(projects.final_mark / 10, 12) bucket, COUNT(*) count").
+			Scopes(scope).
+			Where("projects.status = 'finished'").
+			Group("bucket").
+			Order("bucket").
+			Scan(&marks).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get marks: %w", err))
+			return
+		}
+
+		// Without a campus, compare campuses,
+		// else compare promos of that campus
+		breakdownColumn := "TO_CHAR(users.begin_at, 'MM/YYYY')"
+		breakdownOrder := "MIN(users.begin_at) DESC"
+		if campusID == -1 {
+			breakdownColumn = "campuses.name"
+			breakdownOrder = "users DESC"
+		}
+		var breakdown []templates.SubjectStats
+		err = db.
+			Model(&models.Project{}).
+			Select(breakdownColumn + " AS name, " + subjectAggregates).
+			Scopes(scope).
+			Joins("JOIN campuses ON campuses.id = users.campus_id").
+			Group(breakdownColumn).
+			Order(breakdownOrder).
+			Scan(&breakdown).Error
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to get breakdown: %w", err))
+			return
+		}
+
+		_ = templates.SubjectAnalytics(stats, marks, breakdown,
+			subjects, subjectID, campuses, campusID, promos,
+		).Render(r.Context(), w)
+	})
+}
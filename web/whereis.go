@@ -0,0 +1,109 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/demostanis/42evaluators/internal/clusters"
+	"github.com/demostanis/42evaluators/internal/models"
+	"gorm.io/gorm"
+)
+
+type whereisResponse struct {
+	Login    string `json:"login"`
+	Host     string `json:"host"`
+	CampusID int    `json:"campus_id"`
+	// Zero when the host isn't on any cluster map we know of
+	ClusterID   int    `json:"cluster_id"`
+	ClusterName string `json:"cluster_name"`
+	Since       string `json:"since"`
+}
+
+// Users who hid their location can only be found by themselves
+func isLocationHidden(db *gorm.DB, r *http.Request, userID int) (bool, error) {
+	if userID == getLoggedInUser(r).them.ID {
+		return false, nil
+	}
+	var hidden bool
+	err := db.
+		Model(&models.User{}).
+		Select("hide_location").
+		Where("id = ?", userID).
+		Limit(1).
+		Find(&hidden).Error
+	return hidden, err
+}
+
+// Returns where ?login= is logged in. People who are not on
+// campus and people who hid their location are both not found.
+func whereisAPI(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := strings.TrimSpace(r.URL.Query().Get("login"))
+		if login == "" {
+			http.Error(w, "login should be given", http.StatusBadRequest)
+			return
+		}
+
+		var location models.Location
+		err := db.
+			Model(&models.Location{}).
+			Where("login = ?", login).
+			Order("begin_at DESC").
+			First(&location).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "not on campus", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find location: %w", err))
+			return
+		}
+		hidden, err := isLocationHidden(db, r, location.UserID)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to find user: %w", err))
+			return
+		}
+		if hidden {
+			http.Error(w, "not on campus", http.StatusNotFound)
+			return
+		}
+
+		response := whereisResponse{
+			Login:    location.Login,
+			Host:     location.Host,
+			CampusID: location.CampusID,
+			Since:    location.BeginAt,
+		}
+		cluster, found := clusters.FindClusterOfHost(location.CampusID, location.Host)
+		if found {
+			response.ClusterID = cluster.ID
+			response.ClusterName = cluster.DisplayName
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+// Lets the logged in user hide their location
+// (?hide=true) or show it again (?hide=false)
+func handleLocationPrivacy(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		me := getLoggedInUser(r).them
+		user := models.User{ID: me.ID}
+		err := user.SetHideLocation(r.FormValue("hide") == "true", db)
+		if err != nil {
+			internalServerError(w, fmt.Errorf("failed to update user: %w", err))
+			return
+		}
+		http.Redirect(w, r, "/users/"+me.Login, http.StatusSeeOther)
+	})
+}